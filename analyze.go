@@ -0,0 +1,269 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Report describes problems found in a file by Analyze.
+type Report struct {
+	// Frames contains the number of audio frames, excluding any Xing, Info, or VBRI frame.
+	Frames int64
+	// Bytes contains the total size of all frames, including any Xing, Info, or VBRI frame.
+	Bytes int64
+	// VBR contains information from the first frame's Xing, Info, or VBRI header.
+	// It is nil if no header was found.
+	VBR *VBRInfo
+	// Issues describes problems in the order in which they were encountered.
+	Issues []Issue
+}
+
+// MaxSeverity returns the most severe level across all of the report's issues.
+// SeverityInfo is returned if there are no issues.
+func (rep *Report) MaxSeverity() Severity {
+	max := SeverityInfo
+	for _, is := range rep.Issues {
+		if is.Severity > max {
+			max = is.Severity
+		}
+	}
+	return max
+}
+
+// Issue describes a single problem found by Analyze.
+type Issue struct {
+	// Type describes the type of problem.
+	Type IssueType
+	// Severity describes how serious the problem is.
+	Severity Severity
+	// Offset contains the offset from the beginning of the file of the problematic data.
+	Offset int64
+	// Length contains the length in bytes of the problematic data. It is 0 if the
+	// issue doesn't describe a region of the file.
+	Length int64
+	// Message contains a human-readable description of the problem.
+	Message string
+}
+
+func (is Issue) String() string {
+	s := fmt.Sprintf("%v at %#x", is.Severity, is.Offset)
+	if is.Length > 0 {
+		s += fmt.Sprintf(" (%d bytes)", is.Length)
+	}
+	return s + ": " + is.Message
+}
+
+// IssueType describes a type of problem found by Analyze.
+type IssueType int
+
+const (
+	// NoFrames indicates that no audio frames were found.
+	NoFrames IssueType = iota
+	// JunkBeforeAudio indicates that unparseable data precedes the first frame.
+	JunkBeforeAudio
+	// LostSync indicates that unparseable data was found between two frames.
+	LostSync
+	// TrailingJunk indicates that unparseable data follows the final frame.
+	TrailingJunk
+	// TruncatedFrame indicates that the final frame extends past the end of the audio data.
+	TruncatedFrame
+	// FrameCountMismatch indicates that the number of frames in the VBR header is incorrect.
+	FrameCountMismatch
+	// ByteCountMismatch indicates that the number of bytes in the VBR header is incorrect.
+	ByteCountMismatch
+	// MixedSampleRates indicates that frames have differing sample rates.
+	MixedSampleRates
+	// MixedChannelModes indicates that frames have differing channel modes.
+	MixedChannelModes
+	// MisplacedTag indicates that a tag was found within the audio data.
+	MisplacedTag
+	// BadVBRHeader indicates that the first frame contains a malformed Xing, Info, or VBRI header.
+	BadVBRHeader
+)
+
+var issueTypeNames = map[IssueType]string{
+	NoFrames:           "no frames",
+	JunkBeforeAudio:    "junk before audio",
+	LostSync:           "lost sync",
+	TrailingJunk:       "trailing junk",
+	TruncatedFrame:     "truncated frame",
+	FrameCountMismatch: "frame count mismatch",
+	ByteCountMismatch:  "byte count mismatch",
+	MixedSampleRates:   "mixed sample rates",
+	MixedChannelModes:  "mixed channel modes",
+	MisplacedTag:       "misplaced tag",
+	BadVBRHeader:       "bad VBR header",
+}
+
+func (t IssueType) String() string {
+	if s, ok := issueTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("invalid (%d)", int(t))
+}
+
+// Severity describes how serious an Issue is.
+type Severity int
+
+const (
+	// SeverityInfo indicates that the issue is unlikely to affect playback.
+	SeverityInfo Severity = iota
+	// SeverityWarning indicates that the issue may confuse some players or tools
+	// (e.g. producing an incorrect duration).
+	SeverityWarning
+	// SeverityError indicates that the audio data is damaged.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(s))
+	}
+}
+
+// tagSignatures maps from the IDs at the beginning of various tags to descriptions of the tags.
+var tagSignatures = []struct {
+	id   string
+	desc string
+}{
+	{"ID3", "ID3v2 tag"},
+	{"TAG+", "Enhanced ID3v1 tag"},
+	{"TAG", "ID3v1 tag"},
+	{"APETAGEX", "APE tag"},
+	{"LYRICSBEGIN", "Lyrics3 tag"},
+}
+
+// Analyze walks all of the frames in f and reports problems. headerLen and footerLen are
+// described by NewFrameScanner. An error is only returned if f couldn't be read.
+func Analyze(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (*Report, error) {
+	return AnalyzeFrom(f, fi.Size(), headerLen, footerLen)
+}
+
+// AnalyzeFrom is similar to Analyze but reads from r, which contains size bytes.
+func AnalyzeFrom(r io.ReaderAt, size, headerLen, footerLen int64) (*Report, error) {
+	var rep Report
+	add := func(typ IssueType, sev Severity, off, n int64, format string, args ...interface{}) {
+		rep.Issues = append(rep.Issues, Issue{typ, sev, off, n, fmt.Sprintf(format, args...)})
+	}
+
+	// addJunk adds an issue describing n unparseable bytes at off.
+	addJunk := func(typ IssueType, sev Severity, off, n int64, desc string) error {
+		b := make([]byte, 11)
+		nr, err := r.ReadAt(b, off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		for _, sig := range tagSignatures {
+			if int64(len(sig.id)) <= n && bytes.HasPrefix(b[:nr], []byte(sig.id)) {
+				add(MisplacedTag, SeverityWarning, off, n, "%s found %s", sig.desc, desc)
+				return nil
+			}
+		}
+		add(typ, sev, off, n, "%d unparseable byte(s) %s", n, desc)
+		return nil
+	}
+
+	var vbrOff int64     // offset of frame containing VBR header
+	var first *FrameInfo // first audio frame
+	var mixedRates, mixedModes bool
+	s := NewFrameScanner(r, size, headerLen, footerLen)
+	for n := 0; s.Next(); n++ {
+		f := s.Frame()
+		if f.Skipped > 0 {
+			off := f.Offset - f.Skipped
+			var err error
+			if n == 0 {
+				// ComputeAudioDuration gives up if it needs to look too far for the first frame.
+				sev := SeverityWarning
+				if f.Skipped >= maxFrameSearchBytes {
+					sev = SeverityError
+				}
+				err = addJunk(JunkBeforeAudio, sev, off, f.Skipped, "before first frame")
+			} else {
+				err = addJunk(LostSync, SeverityError, off, f.Skipped, "between frames")
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		rep.Bytes += f.Size
+
+		if n == 0 {
+			vbr, err := readVBRInfo(r, size, f.Offset, f.Info)
+			if isBadVBRHeader(err) {
+				// The frame holds a VBR header, so it doesn't contain audio.
+				add(BadVBRHeader, SeverityError, f.Offset, f.Size, "unreadable VBR header: %v", err)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if rep.VBR = vbr; vbr != nil {
+				vbrOff = f.Offset
+				continue
+			}
+		}
+		rep.Frames++
+
+		if first == nil {
+			first = f.Info
+			continue
+		}
+		if !mixedRates && f.Info.SampleRate != first.SampleRate {
+			add(MixedSampleRates, SeverityWarning, f.Offset, f.Size, "frame has sample rate %d Hz; first frame has %d Hz",
+				f.Info.SampleRate, first.SampleRate)
+			mixedRates = true
+		}
+		if !mixedModes && f.Info.ChannelMode != first.ChannelMode {
+			add(MixedChannelModes, SeverityWarning, f.Offset, f.Size, "frame is %v; first frame is %v",
+				f.Info.ChannelMode, first.ChannelMode)
+			mixedModes = true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	end := size - footerLen
+	if rep.Bytes == 0 && s.Truncated() == nil {
+		add(NoFrames, SeverityError, headerLen, end-headerLen, "no frames found")
+		return &rep, nil
+	}
+	if tr := s.Truncated(); tr != nil {
+		if tr.Skipped > 0 {
+			if err := addJunk(LostSync, SeverityError, tr.Offset-tr.Skipped, tr.Skipped, "between frames"); err != nil {
+				return nil, err
+			}
+		}
+		add(TruncatedFrame, SeverityError, tr.Offset, end-tr.Offset, "final frame is truncated to %d of %d byte(s)",
+			end-tr.Offset, tr.Size)
+	} else if n := s.TrailingBytes(); n > 0 {
+		if err := addJunk(TrailingJunk, SeverityWarning, end-n, n, "after final frame"); err != nil {
+			return nil, err
+		}
+	}
+
+	if vbr := rep.VBR; vbr != nil {
+		if int64(vbr.Frames) != rep.Frames {
+			add(FrameCountMismatch, SeverityWarning, vbrOff, 0, "%v header claims %d frame(s); found %d",
+				vbr.ID, vbr.Frames, rep.Frames)
+		}
+		if vbr.Bytes != 0 && int64(vbr.Bytes) != rep.Bytes {
+			add(ByteCountMismatch, SeverityWarning, vbrOff, 0, "%v header claims %d byte(s); found %d",
+				vbr.ID, vbr.Bytes, rep.Bytes)
+		}
+	}
+
+	return &rep, nil
+}
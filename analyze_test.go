@@ -0,0 +1,93 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	const (
+		// MPEG-1 Layer III, no CRC, 128 kbps, 48000 Hz, no padding, stereo.
+		header48k uint32 = 0xfffb9400
+		// Same as testHeader128, but mono.
+		headerMono uint32 = 0xfffb90c0
+	)
+	frame := makeFrame(t, testHeader128, 0)
+	frames := func(n int) []byte { return bytes.Repeat(frame, n) }
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	// Clear the Xing header's frame-count flag.
+	badXing := makeXingFrame(t, 3, 4*417, make([]byte, 100), nil)
+	binary.BigEndian.PutUint32(badXing[xingOffset(&FrameInfo{})+4:], 0x2)
+
+	type issue struct {
+		typ      IssueType
+		sev      Severity
+		off, len int64
+	}
+	for _, tc := range []struct {
+		desc   string
+		data   []byte
+		frames int64
+		want   []issue
+	}{
+		{"clean", frames(3), 3, nil},
+		{"xing", join(makeXingFrame(t, 3, 4*417, make([]byte, 100), nil), frames(3)), 3, nil},
+		{"junk before", join(make([]byte, 10), frames(3)), 3, []issue{
+			{JunkBeforeAudio, SeverityWarning, 0, 10},
+		}},
+		{"lots of junk before", join(make([]byte, maxFrameSearchBytes), frames(3)), 3, []issue{
+			{JunkBeforeAudio, SeverityError, 0, maxFrameSearchBytes},
+		}},
+		{"id3v2 before", join([]byte("ID3\x04\x00"), frames(3)), 3, []issue{
+			{MisplacedTag, SeverityWarning, 0, 5},
+		}},
+		{"lost sync", join(frames(2), make([]byte, 7), frames(2)), 4, []issue{
+			{LostSync, SeverityError, 2 * 417, 7},
+		}},
+		{"truncated", join(frames(3), frame[:100]), 3, []issue{
+			{TruncatedFrame, SeverityError, 3 * 417, 100},
+		}},
+		{"trailing junk", join(frames(3), []byte("junk")), 3, []issue{
+			{TrailingJunk, SeverityWarning, 3 * 417, 4},
+		}},
+		{"ape after", join(frames(3), []byte("APETAGEX"), make([]byte, 24)), 3, []issue{
+			{MisplacedTag, SeverityWarning, 3 * 417, 32},
+		}},
+		{"xing mismatch", join(makeXingFrame(t, 5, 1000, make([]byte, 100), nil), frames(3)), 3, []issue{
+			{FrameCountMismatch, SeverityWarning, 0, 0},
+			{ByteCountMismatch, SeverityWarning, 0, 0},
+		}},
+		{"bad xing", join(badXing, frames(3)), 3, []issue{
+			{BadVBRHeader, SeverityError, 0, 417},
+		}},
+		{"mixed", join(frames(2), makeFrame(t, header48k, 0), makeFrame(t, headerMono, 0), frames(1)), 5, []issue{
+			{MixedSampleRates, SeverityWarning, 2 * 417, 384},
+			{MixedChannelModes, SeverityWarning, 2*417 + 384, 417},
+		}},
+		{"empty", make([]byte, 100), 0, []issue{
+			{NoFrames, SeverityError, 0, 100},
+		}},
+	} {
+		rep, err := AnalyzeFrom(bytes.NewReader(tc.data), int64(len(tc.data)), 0, 0)
+		if err != nil {
+			t.Errorf("AnalyzeFrom failed for %v: %v", tc.desc, err)
+			continue
+		}
+		if rep.Frames != tc.frames {
+			t.Errorf("AnalyzeFrom for %v reported %d frame(s); want %d", tc.desc, rep.Frames, tc.frames)
+		}
+		var got []issue
+		for _, is := range rep.Issues {
+			got = append(got, issue{is.Type, is.Severity, is.Offset, is.Length})
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("AnalyzeFrom for %v reported %v; want %v", tc.desc, rep.Issues, tc.want)
+		}
+	}
+}
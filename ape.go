@@ -0,0 +1,210 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// APETag contains information from an APEv1 or APEv2 tag.
+// See https://wiki.hydrogenaud.io/index.php?title=APEv2_specification.
+type APETag struct {
+	// Version contains the tag's version: 1000 for APEv1 or 2000 for APEv2.
+	Version int
+	// Offset contains the offset of the start of the tag (including its header,
+	// if present) from the beginning of the file.
+	Offset int64
+	// Length contains the total length of the tag in bytes, including its header and footer.
+	Length int64
+	// ReadOnly is true if the tag is marked as read-only.
+	ReadOnly bool
+	// Items contains the tag's items in the order in which they appear.
+	Items []APEItem
+}
+
+// APEItem contains a single item from an APE tag.
+type APEItem struct {
+	// Key contains the item's key, e.g. "Artist" or "REPLAYGAIN_TRACK_GAIN".
+	Key string
+	// Value contains the item's raw value.
+	Value []byte
+	// Type describes the type of data in Value.
+	Type APEItemType
+	// ReadOnly is true if the item is marked as read-only.
+	ReadOnly bool
+}
+
+// Values splits a text or external locator item's value into its UTF-8 strings.
+// APEv2 items may contain multiple values separated by null bytes.
+func (it *APEItem) Values() []string {
+	return strings.Split(string(it.Value), "\x00")
+}
+
+// APEItemType describes the type of data contained in an APEItem.
+type APEItemType int
+
+const (
+	APEText     APEItemType = 0 // UTF-8 text
+	APEBinary   APEItemType = 1 // binary data
+	APEExternal APEItemType = 2 // UTF-8 locator of external data, e.g. a URL
+	APEReserved APEItemType = 3
+)
+
+func (t APEItemType) String() string {
+	switch t {
+	case APEText:
+		return "text"
+	case APEBinary:
+		return "binary"
+	case APEExternal:
+		return "external"
+	case APEReserved:
+		return "reserved"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(t))
+	}
+}
+
+// Get returns the first item with the supplied case-insensitive key, or nil if it isn't present.
+func (tag *APETag) Get(key string) *APEItem {
+	for i := range tag.Items {
+		if strings.EqualFold(tag.Items[i].Key, key) {
+			return &tag.Items[i]
+		}
+	}
+	return nil
+}
+
+const (
+	apeMagic     = "APETAGEX"
+	apeHeaderLen = 32 // length of header and footer
+	apeMaxItems  = 65536
+
+	apeFlagHasHeader = 1 << 31
+	apeFlagIsHeader  = 1 << 29
+	apeFlagReadOnly  = 1 << 0
+	apeFlagTypeShift = 1
+	apeFlagTypeMask  = 0x3 << apeFlagTypeShift
+)
+
+// apeFooter corresponds to the header or footer of an APE tag.
+type apeFooter struct {
+	Magic    [8]byte
+	Version  uint32
+	Size     uint32 // size of items and footer, excluding header
+	Items    uint32
+	Flags    uint32
+	Reserved [8]byte
+}
+
+// ReadAPETag reads an APE tag from the end of f. The tag may either be located
+// at the very end of the file or immediately before an ID3v1 footer.
+// If the tag isn't present, the returned tag and error will be nil.
+func ReadAPETag(f *os.File, fi os.FileInfo) (*APETag, error) {
+	return ReadAPETagFrom(f, fi.Size())
+}
+
+// ReadAPETagFrom is similar to ReadAPETag but reads from the end of r, which contains size bytes.
+func ReadAPETagFrom(r io.ReaderAt, size int64) (*APETag, error) {
+	if tag, err := readAPETag(r, size); err != nil || tag != nil {
+		return tag, err
+	}
+	n, err := id3v1FooterLen(r, size)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	return readAPETag(r, size-n)
+}
+
+// readAPETag reads an APE tag whose footer ends at end in r.
+// If the tag isn't present, nil is returned.
+func readAPETag(r io.ReaderAt, end int64) (*APETag, error) {
+	if end < apeHeaderLen {
+		return nil, nil
+	}
+	b := make([]byte, apeHeaderLen)
+	if _, err := r.ReadAt(b, end-apeHeaderLen); err != nil {
+		return nil, err
+	}
+	var ftr apeFooter
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &ftr); err != nil {
+		return nil, err
+	}
+	if string(ftr.Magic[:]) != apeMagic || ftr.Flags&apeFlagIsHeader != 0 {
+		return nil, nil
+	}
+	if ftr.Version != 1000 && ftr.Version != 2000 {
+		return nil, fmt.Errorf("unsupported APE tag version %d", ftr.Version)
+	}
+	if ftr.Size < apeHeaderLen || int64(ftr.Size) > end {
+		return nil, fmt.Errorf("invalid APE tag size %d", ftr.Size)
+	}
+	if ftr.Items > apeMaxItems {
+		return nil, fmt.Errorf("too many APE items (%d)", ftr.Items)
+	}
+
+	tag := APETag{
+		Version: int(ftr.Version),
+		Offset:  end - int64(ftr.Size),
+		Length:  int64(ftr.Size),
+	}
+	// APEv1 tags don't have flags or headers.
+	if ftr.Version == 2000 {
+		tag.ReadOnly = ftr.Flags&apeFlagReadOnly != 0
+		if ftr.Flags&apeFlagHasHeader != 0 {
+			if tag.Offset < apeHeaderLen {
+				return nil, errors.New("APE tag header is before start of file")
+			}
+			tag.Offset -= apeHeaderLen
+			tag.Length += apeHeaderLen
+		}
+	}
+
+	b = make([]byte, ftr.Size-apeHeaderLen)
+	if _, err := r.ReadAt(b, end-int64(ftr.Size)); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(ftr.Items); i++ {
+		item, n, err := parseAPEItem(b, ftr.Version)
+		if err != nil {
+			return nil, fmt.Errorf("APE item %d: %v", i, err)
+		}
+		tag.Items = append(tag.Items, item)
+		b = b[n:]
+	}
+	return &tag, nil
+}
+
+// parseAPEItem parses the APE item at the beginning of b and returns it and its length.
+func parseAPEItem(b []byte, version uint32) (APEItem, int, error) {
+	if len(b) < 8 {
+		return APEItem{}, 0, errors.New("truncated item header")
+	}
+	valueLen := binary.LittleEndian.Uint32(b[0:4])
+	flags := binary.LittleEndian.Uint32(b[4:8])
+	keyEnd := bytes.IndexByte(b[8:], 0)
+	if keyEnd < 0 {
+		return APEItem{}, 0, errors.New("unterminated key")
+	}
+	valueStart := 8 + keyEnd + 1
+	if uint64(valueStart)+uint64(valueLen) > uint64(len(b)) {
+		return APEItem{}, 0, fmt.Errorf("%d-byte value extends past end of tag", valueLen)
+	}
+	item := APEItem{
+		Key:   string(b[8 : 8+keyEnd]),
+		Value: b[valueStart : valueStart+int(valueLen)],
+	}
+	// APEv1 items are always text.
+	if version == 2000 {
+		item.Type = APEItemType((flags & apeFlagTypeMask) >> apeFlagTypeShift)
+		item.ReadOnly = flags&apeFlagReadOnly != 0
+	}
+	return item, valueStart + int(valueLen), nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// makeAPETag returns an APE tag with the supplied version containing items.
+// A header is included if header is true.
+func makeAPETag(version uint32, header bool, items []APEItem) []byte {
+	var body bytes.Buffer
+	for _, it := range items {
+		flags := uint32(it.Type) << apeFlagTypeShift
+		if it.ReadOnly {
+			flags |= apeFlagReadOnly
+		}
+		binary.Write(&body, binary.LittleEndian, uint32(len(it.Value)))
+		binary.Write(&body, binary.LittleEndian, flags)
+		body.WriteString(it.Key)
+		body.WriteByte(0)
+		body.Write(it.Value)
+	}
+
+	makeFooter := func(flags uint32) []byte {
+		var b bytes.Buffer
+		ftr := apeFooter{
+			Version: version,
+			Size:    uint32(body.Len() + apeHeaderLen),
+			Items:   uint32(len(items)),
+			Flags:   flags,
+		}
+		copy(ftr.Magic[:], apeMagic)
+		binary.Write(&b, binary.LittleEndian, &ftr)
+		return b.Bytes()
+	}
+
+	var b []byte
+	var flags uint32
+	if header {
+		flags |= apeFlagHasHeader
+		b = append(b, makeFooter(flags|apeFlagIsHeader)...)
+	}
+	b = append(b, body.Bytes()...)
+	return append(b, makeFooter(flags)...)
+}
+
+func TestReadAPETagFrom(t *testing.T) {
+	items := []APEItem{
+		{Key: "Artist", Value: []byte("Artist 1\x00Artist 2"), Type: APEText},
+		{Key: "Cover Art (Front)", Value: []byte("cover.jpg\x00\xff\xd8\xff"), Type: APEBinary, ReadOnly: true},
+		{Key: "Lyrics", Value: []byte("https://example.org/lyrics"), Type: APEExternal},
+	}
+	audio := bytes.Repeat([]byte{0xff}, 100)
+	id3v1 := makeID3v1Footer("Title", "", "", "", "", 0, 0)
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	for _, tc := range []struct {
+		desc    string
+		tag     []byte
+		after   []byte // data following tag
+		version int
+		items   []APEItem
+	}{
+		{"v2 with header", makeAPETag(2000, true, items), nil, 2000, items},
+		{"v2 without header", makeAPETag(2000, false, items), nil, 2000, items},
+		{"v2 before ID3v1", makeAPETag(2000, true, items), id3v1, 2000, items},
+		{"v1", makeAPETag(1000, false, items[:1]), nil, 1000, items[:1]},
+		{"none", nil, id3v1, 0, nil},
+	} {
+		data := join(audio, tc.tag, tc.after)
+		tag, err := ReadAPETagFrom(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Errorf("ReadAPETagFrom failed for %v: %v", tc.desc, err)
+			continue
+		}
+		if tc.items == nil {
+			if tag != nil {
+				t.Errorf("ReadAPETagFrom returned %+v for %v", tag, tc.desc)
+			}
+			continue
+		}
+		if tag == nil {
+			t.Errorf("ReadAPETagFrom didn't find tag for %v", tc.desc)
+			continue
+		}
+		if tag.Version != tc.version || tag.Offset != int64(len(audio)) || tag.Length != int64(len(tc.tag)) {
+			t.Errorf("ReadAPETagFrom for %v returned version %d, offset %d, length %d; want %d, %d, %d",
+				tc.desc, tag.Version, tag.Offset, tag.Length, tc.version, len(audio), len(tc.tag))
+		}
+		if !reflect.DeepEqual(tag.Items, tc.items) {
+			t.Errorf("ReadAPETagFrom for %v returned items %+v; want %+v", tc.desc, tag.Items, tc.items)
+		}
+	}
+}
+
+func TestAPETag_Get(t *testing.T) {
+	tag := APETag{Items: []APEItem{
+		{Key: "Artist", Value: []byte("Artist 1\x00Artist 2")},
+		{Key: "Title", Value: []byte("Title")},
+	}}
+	if it := tag.Get("ARTIST"); it == nil {
+		t.Error("Get(\"ARTIST\") didn't find item")
+	} else if got, want := it.Values(), []string{"Artist 1", "Artist 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %q; want %q", got, want)
+	}
+	if it := tag.Get("Album"); it != nil {
+		t.Errorf("Get(\"Album\") = %+v; want nil", it)
+	}
+}
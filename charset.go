@@ -0,0 +1,108 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"unicode/utf8"
+)
+
+// TextDecoder converts text in a legacy character set to UTF-8.
+// It is satisfied by *encoding.Decoder from golang.org/x/text/encoding,
+// so e.g. japanese.ShiftJIS.NewDecoder() may be used.
+type TextDecoder interface {
+	// Bytes returns the UTF-8 representation of b.
+	Bytes(b []byte) ([]byte, error)
+}
+
+var (
+	// Latin1 decodes ISO-8859-1 text. It is the default for ID3v1 tags.
+	Latin1 TextDecoder = charmapDecoder{nil}
+	// Windows1251 decodes Windows-1251 (Cyrillic) text.
+	Windows1251 TextDecoder = charmapDecoder{&windows1251High}
+	// AutoDetect guesses whether text is UTF-8, Windows-1251, or ISO-8859-1. Valid UTF-8
+	// containing non-ASCII characters is returned unchanged; note that this check runs first,
+	// so legacy text that also happens to be valid UTF-8 is treated as UTF-8. Otherwise, text
+	// in which most letters are in the upper half of the Windows-1251 table is decoded as
+	// Windows-1251. All other text is decoded as ISO-8859-1. Other encodings (e.g. Shift-JIS)
+	// are not detected; supply a specific TextDecoder for them instead.
+	AutoDetect TextDecoder = autoDecoder{}
+)
+
+// charmapDecoder decodes a single-byte character set in which the lower 128 bytes are ASCII.
+type charmapDecoder struct {
+	// high maps from bytes 0x80-0xff to runes. If nil, bytes are mapped to
+	// the corresponding Unicode code points (i.e. ISO-8859-1).
+	high *[128]rune
+}
+
+func (d charmapDecoder) Bytes(b []byte) ([]byte, error) {
+	out := make([]byte, 0, len(b))
+	for _, ch := range b {
+		switch {
+		case ch < 0x80:
+			out = append(out, ch)
+		case d.high == nil:
+			out = appendRune(out, rune(ch))
+		default:
+			out = appendRune(out, d.high[ch-0x80])
+		}
+	}
+	return out, nil
+}
+
+// appendRune appends the UTF-8 encoding of r to b.
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+// windows1251High contains the runes for bytes 0x80-0xff in Windows-1251.
+// See https://en.wikipedia.org/wiki/Windows-1251.
+var windows1251High = func() [128]rune {
+	t := [128]rune{
+		// 0x80
+		0x0402, 0x0403, 0x201a, 0x0453, 0x201e, 0x2026, 0x2020, 0x2021,
+		0x20ac, 0x2030, 0x0409, 0x2039, 0x040a, 0x040c, 0x040b, 0x040f,
+		// 0x90
+		0x0452, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+		utf8.RuneError, 0x2122, 0x0459, 0x203a, 0x045a, 0x045c, 0x045b, 0x045f,
+		// 0xa0
+		0x00a0, 0x040e, 0x045e, 0x0408, 0x00a4, 0x0490, 0x00a6, 0x00a7,
+		0x0401, 0x00a9, 0x0404, 0x00ab, 0x00ac, 0x00ad, 0x00ae, 0x0407,
+		// 0xb0
+		0x00b0, 0x00b1, 0x0406, 0x0456, 0x0491, 0x00b5, 0x00b6, 0x00b7,
+		0x0451, 0x2116, 0x0454, 0x00bb, 0x0458, 0x0405, 0x0455, 0x0457,
+	}
+	// 0xc0-0xff contain the basic Cyrillic alphabet in order.
+	for i := 0x40; i < 0x80; i++ {
+		t[i] = rune(0x0410 + i - 0x40)
+	}
+	return t
+}()
+
+// autoDecoder implements AutoDetect.
+type autoDecoder struct{}
+
+func (d autoDecoder) Bytes(b []byte) ([]byte, error) {
+	var ascii, high int // counts of ASCII letters and bytes in 0xc0-0xff
+	for _, ch := range b {
+		switch {
+		case (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z'):
+			ascii++
+		case ch >= 0xc0:
+			high++
+		}
+	}
+	switch {
+	case high == 0:
+		return Latin1.Bytes(b)
+	case utf8.Valid(b):
+		return append([]byte{}, b...), nil
+	case high > ascii:
+		return Windows1251.Bytes(b)
+	default:
+		return Latin1.Bytes(b)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"testing"
+)
+
+func TestTextDecoders(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		dec  TextDecoder
+		in   string
+		want string
+	}{
+		{"Latin1", Latin1, "Caf\xe9 \xbd", "Café ½"},
+		{"Windows1251", Windows1251, "\xcf\xf0\xe8\xe2\xe5\xf2 \xa8\xb8 \x88", "Привет Ёё €"},
+		{"AutoDetect", AutoDetect, "ASCII", "ASCII"},
+		{"AutoDetect", AutoDetect, "Caf\xe9 au lait", "Café au lait"},
+		{"AutoDetect", AutoDetect, "Caf\xc3\xa9", "Café"},
+		{"AutoDetect", AutoDetect, "\xca\xe8\xed\xee 2", "Кино 2"},
+		{"AutoDetect", AutoDetect, "\xd0\xb0\xd0\xb1", "аб"},                            // also valid Windows-1251
+		{"AutoDetect", AutoDetect, "Caf\xe9 \xca\xe8\xed\xee", "Cafй Кино"},             // more high bytes than ASCII letters
+		{"AutoDetect", AutoDetect, "R\xe9sum\xe9 \xca", "Résumé Ê"},                     // more ASCII letters than high bytes
+		{"AutoDetect", AutoDetect, "ab\xe9\xe8", "abéè"},                                // tie
+		{"AutoDetect", AutoDetect, "\x83\x65\x83\x58\x83\x67", "\u0083e\u0083X\u0083g"}, // Shift-JIS isn't detected
+	} {
+		if got, err := tc.dec.Bytes([]byte(tc.in)); err != nil {
+			t.Errorf("%v.Bytes(%q) failed: %v", tc.desc, tc.in, err)
+		} else if string(got) != tc.want {
+			t.Errorf("%v.Bytes(%q) = %q; want %q", tc.desc, tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CRCReport contains the results of verifying the CRCs of all of the frames in a file.
+type CRCReport struct {
+	// Frames contains the total number of frames that were found.
+	Frames int
+	// Checked contains the number of frames with CRCs that were checked.
+	Checked int
+	// Mismatches describes frames whose stored CRCs didn't match their data.
+	Mismatches []CRCMismatch
+}
+
+// CRCMismatch describes a frame whose stored CRC didn't match its data.
+type CRCMismatch struct {
+	// Offset contains the offset of the frame's header from the beginning of the file.
+	Offset int64
+	// Stored contains the CRC stored in the frame.
+	Stored uint16
+	// Computed contains the CRC computed from the frame's header and side information.
+	Computed uint16
+}
+
+// VerifyFrameCRCs checks the CRC-16 of each frame in f that has HasCRC set.
+// headerLen and footerLen are described by NewFrameScanner.
+func VerifyFrameCRCs(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (*CRCReport, error) {
+	return VerifyFrameCRCsFrom(f, fi.Size(), headerLen, footerLen)
+}
+
+// VerifyFrameCRCsFrom is similar to VerifyFrameCRCs but reads from r, which contains size bytes.
+func VerifyFrameCRCsFrom(r io.ReaderAt, size, headerLen, footerLen int64) (*CRCReport, error) {
+	var rep CRCReport
+	s := NewFrameScanner(r, size, headerLen, footerLen)
+	for s.Next() {
+		f := s.Frame()
+		rep.Frames++
+		if !f.Info.HasCRC {
+			continue
+		}
+		stored, computed, err := ReadFrameCRCFrom(r, f.Offset, f.Info)
+		if err != nil {
+			return nil, fmt.Errorf("frame at %#x: %v", f.Offset, err)
+		}
+		rep.Checked++
+		if stored != computed {
+			rep.Mismatches = append(rep.Mismatches, CRCMismatch{f.Offset, stored, computed})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// ReadFrameCRC reads the frame described by fi at off in f and returns its stored CRC-16
+// and the CRC-16 computed over the last two bytes of its header and its protected data
+// (the side information in Layer III, or the bit allocation and scale factor selection
+// information in Layers I and II). See section 2.4.3.1 of ISO/IEC 11172-3.
+func ReadFrameCRC(f *os.File, off int64, fi *FrameInfo) (stored, computed uint16, err error) {
+	return ReadFrameCRCFrom(f, off, fi)
+}
+
+// ReadFrameCRCFrom is similar to ReadFrameCRC but reads from r.
+func ReadFrameCRCFrom(r io.ReaderAt, off int64, fi *FrameInfo) (stored, computed uint16, err error) {
+	if !fi.HasCRC {
+		return 0, 0, errors.New("frame doesn't have CRC")
+	}
+	b := make([]byte, fi.Size())
+	if _, err := r.ReadAt(b, off); err != nil {
+		return 0, 0, err
+	}
+	if len(b) < 6 {
+		return 0, 0, fmt.Errorf("frame too short (%d bytes)", len(b))
+	}
+	stored = uint16(b[4])<<8 | uint16(b[5])
+	data := b[6:]
+
+	var nbits int
+	switch fi.Layer {
+	case Layer1:
+		nbits = layer1ProtectedBits(fi)
+	case Layer2:
+		nbits = layer2ProtectedBits(data, fi)
+	case Layer3:
+		nbits = 8 * sideInfoLen(fi)
+	}
+	if nbits > 8*len(data) {
+		return 0, 0, fmt.Errorf("frame too short for %d protected bits", nbits)
+	}
+
+	crc := uint16(0xffff)
+	crc = updateMPEGCRC(crc, &bitReader{b: b[2:4]}, 16)
+	crc = updateMPEGCRC(crc, &bitReader{b: data}, nbits)
+	return stored, crc, nil
+}
+
+// updateMPEGCRC updates crc with the next n bits from br using the CRC-16 polynomial
+// 0x8005, as described in section 2.4.3.1 of ISO/IEC 11172-3.
+func updateMPEGCRC(crc uint16, br *bitReader, n int) uint16 {
+	for i := 0; i < n; i++ {
+		bit := uint16(br.read(1))
+		if (crc>>15)^bit != 0 {
+			crc = crc<<1 ^ 0x8005
+		} else {
+			crc <<= 1
+		}
+	}
+	return crc
+}
+
+// layer1ProtectedBits returns the number of bits of bit allocation information
+// protected by the CRC in the Layer I frame described by fi.
+func layer1ProtectedBits(fi *FrameInfo) int {
+	const sblimit = 32
+	nch := fi.channels()
+	bound := sblimit
+	if fi.ChannelMode == JointStereo {
+		bound = fi.ModeExtension.IntensityBound()
+	}
+	// Each subband has a 4-bit allocation per channel, but subbands at and
+	// above the intensity stereo bound share a single allocation.
+	return 4 * (nch*bound + (sblimit - bound))
+}
+
+// layer2ProtectedBits returns the number of bits of bit allocation and scale factor
+// selection information protected by the CRC in the Layer II frame described by fi.
+// data should contain the frame's data following the CRC.
+func layer2ProtectedBits(data []byte, fi *FrameInfo) int {
+	nbal := layer2AllocBits(fi)
+	sblimit := len(nbal)
+	nch := fi.channels()
+	bound := sblimit
+	if fi.ChannelMode == JointStereo {
+		if bound = fi.ModeExtension.IntensityBound(); bound > sblimit {
+			bound = sblimit
+		}
+	}
+
+	// Read the bit allocations to determine which subbands have scale factor selection info.
+	br := bitReader{b: data}
+	nscfsi := 0
+	for sb := 0; sb < sblimit; sb++ {
+		if sb < bound {
+			for ch := 0; ch < nch; ch++ {
+				if br.read(nbal[sb]) != 0 {
+					nscfsi++
+				}
+			}
+		} else if br.read(nbal[sb]) != 0 {
+			nscfsi += nch // allocation is shared by both channels
+		}
+	}
+	return br.pos + 2*nscfsi
+}
+
+// layer2AllocBits returns the number of bits used for each subband's bit allocation in the
+// Layer II frame described by fi. The length of the returned slice is the frame's sblimit.
+// See tables B.2a-d of ISO/IEC 11172-3 and table B.1 of ISO/IEC 13818-3.
+func layer2AllocBits(fi *FrameInfo) []int {
+	repeat := func(counts ...int) []int { // pairs of (number of subbands, bits)
+		var bits []int
+		for i := 0; i < len(counts); i += 2 {
+			for j := 0; j < counts[i]; j++ {
+				bits = append(bits, counts[i+1])
+			}
+		}
+		return bits
+	}
+
+	if fi.Version != Version1 {
+		return repeat(4, 4, 7, 3, 19, 2)
+	}
+	switch br := fi.KbitRate / fi.channels(); {
+	case (fi.SampleRate == 48000 && br >= 56) || (br >= 56 && br <= 80):
+		return repeat(11, 4, 12, 3, 4, 2) // table B.2a
+	case fi.SampleRate != 48000 && br >= 96:
+		return repeat(11, 4, 12, 3, 7, 2) // table B.2b
+	case fi.SampleRate != 32000 && br <= 48:
+		return repeat(2, 4, 6, 3) // table B.2c
+	default:
+		return repeat(2, 4, 10, 3) // table B.2d
+	}
+}
+
+// updateLAMECRC updates crc with b using the CRC-16 variant used by LAME tags
+// (reflected polynomial 0x8005, also known as CRC-16/ARC when starting with 0).
+func updateLAMECRC(crc uint16, b []byte) uint16 {
+	for _, ch := range b {
+		crc ^= uint16(ch)
+		for i := 0; i < 8; i++ {
+			if crc&0x1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
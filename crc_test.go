@@ -0,0 +1,99 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUpdateMPEGCRC(t *testing.T) {
+	// This is the check value for CRC-16/CMS, which uses the same parameters as MPEG audio.
+	data := []byte("123456789")
+	if got, want := updateMPEGCRC(0xffff, &bitReader{b: data}, 8*len(data)), uint16(0xaee7); got != want {
+		t.Errorf("updateMPEGCRC(%q) = %#04x; want %#04x", data, got, want)
+	}
+}
+
+func TestUpdateLAMECRC(t *testing.T) {
+	// This is the check value for CRC-16/ARC.
+	data := []byte("123456789")
+	if got, want := updateLAMECRC(0, data), uint16(0xbb3d); got != want {
+		t.Errorf("updateLAMECRC(%q) = %#04x; want %#04x", data, got, want)
+	}
+}
+
+func TestVerifyFrameCRCs(t *testing.T) {
+	const header = 0xfffa9000 // MPEG-1 Layer III, 128 kbps, 44100 Hz, with CRC
+	makeCRCFrame := func(fill byte) []byte {
+		f := makeFrame(t, header, fill)
+		crc := updateMPEGCRC(0xffff, &bitReader{b: f[2:4]}, 16)
+		crc = updateMPEGCRC(crc, &bitReader{b: f[6:]}, 8*32)
+		f[4], f[5] = byte(crc>>8), byte(crc)
+		return f
+	}
+
+	var data []byte
+	for i := 0; i < 5; i++ {
+		data = append(data, makeCRCFrame(byte(i))...)
+	}
+	data = append(data, makeFrame(t, testHeader128, 0)...) // no CRC
+	bad := makeCRCFrame(0x55)
+	bad[10] ^= 0x1 // flip a bit in the side info
+	badOff := int64(len(data))
+	data = append(data, bad...)
+
+	rep, err := VerifyFrameCRCsFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+	if err != nil {
+		t.Fatal("VerifyFrameCRCsFrom failed: ", err)
+	}
+	stored := uint16(bad[4])<<8 | uint16(bad[5])
+	if rep.Frames != 7 || rep.Checked != 6 || len(rep.Mismatches) != 1 ||
+		rep.Mismatches[0].Offset != badOff || rep.Mismatches[0].Stored != stored {
+		t.Errorf("VerifyFrameCRCsFrom returned %+v; want 7 frames, 6 checked, mismatch at %d with stored %#04x",
+			rep, badOff, stored)
+	}
+}
+
+func TestLayer2AllocBits(t *testing.T) {
+	for _, tc := range []struct {
+		header  uint32
+		sblimit int
+	}{
+		{0xfffd8000, 27}, // MPEG-1 Layer II, 128 kbps, 44100 Hz, stereo
+		{0xfffd8800, 27}, // MPEG-1 Layer II, 128 kbps, 32000 Hz, stereo
+		{0xfffda000, 30}, // MPEG-1 Layer II, 192 kbps, 44100 Hz, stereo
+		{0xfffda400, 27}, // MPEG-1 Layer II, 192 kbps, 48000 Hz, stereo
+		{0xfffd80c0, 30}, // MPEG-1 Layer II, 128 kbps, 44100 Hz, mono
+		{0xfffd2000, 8},  // MPEG-1 Layer II, 48 kbps, 44100 Hz, stereo
+		{0xfffd2800, 12}, // MPEG-1 Layer II, 48 kbps, 32000 Hz, stereo
+		{0xfff58000, 30}, // MPEG-2 Layer II, 64 kbps, 22050 Hz, stereo
+	} {
+		fi, err := parseFrameHeader(tc.header)
+		if err != nil {
+			t.Fatalf("parseFrameHeader(%#x) failed: %v", tc.header, err)
+		}
+		if got := layer2AllocBits(fi); len(got) != tc.sblimit {
+			t.Errorf("layer2AllocBits(%v) returned %d subbands; want %d", fi, len(got), tc.sblimit)
+		}
+	}
+
+	// Check the number of protected bits in a joint stereo frame with a few allocated subbands.
+	fi, err := parseFrameHeader(0xfffda050) // MPEG-1 Layer II, 192 kbps, 44100 Hz, joint stereo (bound 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Subband 0's allocation for channel 0 is nonzero, as is subband 8's shared allocation.
+	// Bits: 8 subbands * 2 channels * 4 bits, then 3 * 4 bits and 12 * 3 bits and 7 * 2 bits.
+	data := make([]byte, 32)
+	data[0] = 0x10
+	br := bitReader{b: data}
+	br.skip(8 * 2 * 4)
+	allocPos := br.pos
+	data[allocPos/8] |= 0x80 >> uint(allocPos%8)
+	want := 8*2*4 + 3*4 + 12*3 + 7*2 + 2*(1+2)
+	if got := layer2ProtectedBits(data, fi); got != want {
+		t.Errorf("layer2ProtectedBits returned %d; want %d", got, want)
+	}
+}
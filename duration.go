@@ -0,0 +1,135 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// ExactDuration contains the results of counting all of the audio frames in a file.
+type ExactDuration struct {
+	// Duration contains the total duration of the audio frames.
+	Duration time.Duration
+	// Frames contains the number of audio frames, excluding any Xing, Info, or VBRI frame.
+	Frames int64
+	// Samples contains the total number of samples (per channel) in the audio frames.
+	Samples int64
+	// SkippedBytes contains the number of bytes that couldn't be parsed as frames,
+	// including any truncated final frame.
+	SkippedBytes int64
+}
+
+// ComputeExactAudioDuration computes the duration of the audio data in f by walking all of its frames.
+// This is slower than ComputeAudioDuration but doesn't depend on the presence of an Xing header or
+// assume that the file has a constant bitrate. headerLen and footerLen are described by NewFrameScanner.
+func ComputeExactAudioDuration(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (*ExactDuration, error) {
+	return ComputeExactAudioDurationFrom(f, fi.Size(), headerLen, footerLen)
+}
+
+// ComputeExactAudioDurationFrom is similar to ComputeExactAudioDuration but reads from r,
+// which contains size bytes.
+func ComputeExactAudioDurationFrom(r io.ReaderAt, size, headerLen, footerLen int64) (*ExactDuration, error) {
+	var ed ExactDuration
+	rateSamples := make(map[int]int64) // total samples keyed by sample rate
+
+	s := NewFrameScanner(r, size, headerLen, footerLen)
+	for first := true; s.Next(); first = false {
+		f := s.Frame()
+		// The first frame may hold a VBR header instead of audio.
+		if first {
+			if vbr, err := hasVBRHeader(r, f.Offset, f.Info); err != nil {
+				return nil, err
+			} else if vbr {
+				continue
+			}
+		}
+		ed.Frames++
+		ed.Samples += int64(f.Info.SamplesPerFrame)
+		rateSamples[f.Info.SampleRate] += int64(f.Info.SamplesPerFrame)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	for rate, samples := range rateSamples {
+		ed.Duration += samplesToDuration(samples, rate)
+	}
+	ed.SkippedBytes = s.SkippedBytes()
+	return &ed, nil
+}
+
+// samplesToDuration returns the duration of the supplied number of samples at rate.
+func samplesToDuration(samples int64, rate int) time.Duration {
+	sec := samples / int64(rate)
+	rem := samples % int64(rate)
+	return time.Duration(sec)*time.Second + time.Duration(rem)*time.Second/time.Duration(rate)
+}
+
+// GaplessInfo describes the playable audio in a file after encoder delay and padding are removed.
+type GaplessInfo struct {
+	// Samples contains the number of playable samples (per channel).
+	Samples int64
+	// SampleRate contains the sample rate in hertz.
+	SampleRate int
+	// EncoderDelay contains the number of samples that were removed from the beginning of the audio.
+	EncoderDelay int
+	// Padding contains the number of samples that were removed from the end of the audio.
+	Padding int
+	// Exact is true if EncoderDelay and Padding were read from a LAME tag.
+	// If false, the delay and padding are unknown and Samples includes them.
+	Exact bool
+}
+
+// Duration returns the playable duration at sample precision.
+func (g *GaplessInfo) Duration() time.Duration {
+	return samplesToDuration(g.Samples, g.SampleRate)
+}
+
+// ComputeGaplessDuration computes the number of playable samples in f using the frame count
+// from an Xing header and the encoder delay and padding from its LAME extension.
+// The decoder delay doesn't need to be considered, since it's cancelled out by LAME's padding.
+//
+// If the file doesn't have a LAME extension, the returned GaplessInfo's Exact field is false and
+// the delay and padding are not removed. If the file lacks an Xing or VBRI header, its frames are
+// counted using ComputeExactAudioDuration.
+func ComputeGaplessDuration(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (*GaplessInfo, error) {
+	return ComputeGaplessDurationFrom(f, fi.Size(), headerLen, footerLen)
+}
+
+// ComputeGaplessDurationFrom is similar to ComputeGaplessDuration but reads from r,
+// which contains size bytes.
+func ComputeGaplessDurationFrom(r io.ReaderAt, size, headerLen, footerLen int64) (*GaplessInfo, error) {
+	fstart, finfo, err := findFirstFrame(r, headerLen)
+	if err != nil {
+		return nil, err
+	}
+	vbrInfo, err := readVBRInfo(r, size, fstart, finfo)
+	if err != nil {
+		return nil, err
+	}
+
+	g := GaplessInfo{SampleRate: finfo.SampleRate}
+	if vbrInfo == nil {
+		ed, err := ComputeExactAudioDurationFrom(r, size, headerLen, footerLen)
+		if err != nil {
+			return nil, err
+		}
+		g.Samples = ed.Samples
+		return &g, nil
+	}
+
+	g.Samples = int64(vbrInfo.Frames) * int64(finfo.SamplesPerFrame)
+	if lame := vbrInfo.LAME; lame != nil {
+		// Ignore obviously-bogus values.
+		if trim := int64(lame.EncoderDelay + lame.Padding); trim < g.Samples {
+			g.Samples -= trim
+			g.EncoderDelay = lame.EncoderDelay
+			g.Padding = lame.Padding
+			g.Exact = true
+		}
+	}
+	return &g, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestComputeExactAudioDuration(t *testing.T) {
+	const nframes = 10
+	info := makeFrame(t, testHeader128, 0)
+	copy(info[36:], InfoID)
+	data := append([]byte{}, info...)
+	for i := 0; i < nframes; i++ {
+		data = append(data, makeFrame(t, testHeader128, 0)...)
+	}
+	data = append(data, 1, 2, 3)
+
+	ed, err := ComputeExactAudioDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+	if err != nil {
+		t.Fatal("ComputeExactAudioDurationFrom failed: ", err)
+	}
+	want := ExactDuration{
+		Duration:     nframes * 1152 * time.Second / 44100,
+		Frames:       nframes,
+		Samples:      nframes * 1152,
+		SkippedBytes: 3,
+	}
+	if *ed != want {
+		t.Errorf("ComputeExactAudioDurationFrom returned %+v; want %+v", *ed, want)
+	}
+}
+
+func TestComputeGaplessDuration(t *testing.T) {
+	const nframes = 10
+	data := makeXingFrame(t, nframes, 0, make([]byte, 100), testLAMETag)
+	for i := 0; i < nframes; i++ {
+		data = append(data, makeFrame(t, testHeader128, 0)...)
+	}
+	g, err := ComputeGaplessDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+	if err != nil {
+		t.Fatal("ComputeGaplessDurationFrom failed: ", err)
+	}
+	const samples = nframes*1152 - 576 - 1104
+	want := GaplessInfo{
+		Samples:      samples,
+		SampleRate:   44100,
+		EncoderDelay: 576,
+		Padding:      1104,
+		Exact:        true,
+	}
+	if *g != want {
+		t.Errorf("ComputeGaplessDurationFrom returned %+v; want %+v", *g, want)
+	}
+	if got, want := g.Duration(), samples*time.Second/44100; got != want {
+		t.Errorf("Duration() = %v; want %v", got, want)
+	}
+
+	// Without an Xing header, all of the frames should be counted.
+	data = data[len(data)/(nframes+1):]
+	if g, err := ComputeGaplessDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0); err != nil {
+		t.Error("ComputeGaplessDurationFrom failed without Xing header: ", err)
+	} else if want := (GaplessInfo{Samples: nframes * 1152, SampleRate: 44100}); *g != want {
+		t.Errorf("ComputeGaplessDurationFrom returned %+v without Xing header; want %+v", *g, want)
+	}
+}
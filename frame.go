@@ -0,0 +1,182 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// FrameType describes the contents of an MPEG audio frame.
+type FrameType int
+
+const (
+	// AudioFrame indicates that the frame contains encoded audio.
+	AudioFrame FrameType = iota
+	// InfoFrame indicates that the frame contains an Xing, Info, or VBRI header rather than audio.
+	InfoFrame
+	// EmptyFrame indicates that the frame doesn't contain any encoded audio, i.e. it decodes to silence.
+	EmptyFrame
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case AudioFrame:
+		return "audio"
+	case InfoFrame:
+		return "info"
+	case EmptyFrame:
+		return "empty"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(t))
+	}
+}
+
+// ReadFrameType reads the frame described by fi at off in f and returns its type.
+//
+// Layer III frames are considered empty if their side information indicates that none of
+// their granules contain any main data. Layer I and II frames are considered empty if all
+// of the bytes following the header are zero.
+func ReadFrameType(f *os.File, off int64, fi *FrameInfo) (FrameType, error) {
+	return ReadFrameTypeFrom(f, off, fi)
+}
+
+// ReadFrameTypeFrom is similar to ReadFrameType but reads from r.
+func ReadFrameTypeFrom(r io.ReaderAt, off int64, fi *FrameInfo) (FrameType, error) {
+	min := 4 // header
+	if fi.HasCRC {
+		min += 2
+	}
+	if fi.Layer == Layer3 {
+		min += sideInfoLen(fi)
+	}
+	if fi.Size() < int64(min) {
+		return AudioFrame, fmt.Errorf("frame too short (%d bytes)", fi.Size())
+	}
+
+	if info, err := hasVBRHeader(r, off, fi); err != nil {
+		return AudioFrame, err
+	} else if info {
+		return InfoFrame, nil
+	}
+
+	b := make([]byte, fi.Size())
+	if _, err := r.ReadAt(b, off); err != nil {
+		return AudioFrame, err
+	}
+	b = b[4:] // skip header
+	if fi.HasCRC {
+		b = b[2:]
+	}
+
+	if fi.Layer != Layer3 {
+		for _, ch := range b {
+			if ch != 0 {
+				return AudioFrame, nil
+			}
+		}
+		return EmptyFrame, nil
+	}
+
+	si, err := parseSideInfo(b, fi)
+	if err != nil {
+		return AudioFrame, err
+	}
+	for _, n := range si.part23Lengths {
+		if n != 0 {
+			return AudioFrame, nil
+		}
+	}
+	return EmptyFrame, nil
+}
+
+// channels returns the number of channels in the frame described by fi.
+func (fi *FrameInfo) channels() int {
+	if fi.ChannelMode == SingleChannel {
+		return 1
+	}
+	return 2
+}
+
+// sideInfoLen returns the length in bytes of the side information in the Layer III frame
+// described by fi. The side information immediately follows the header (and CRC, if present).
+func sideInfoLen(fi *FrameInfo) int {
+	mono := fi.ChannelMode == SingleChannel
+	switch {
+	case fi.Version == Version1 && mono:
+		return 17
+	case fi.Version == Version1:
+		return 32
+	case mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// sideInfo contains information from a Layer III frame's side information.
+type sideInfo struct {
+	mainDataBegin int   // negative offset of frame's main data in bit reservoir
+	part23Lengths []int // number of main data bits per granule and channel
+}
+
+// parseSideInfo parses the side information at the beginning of b for the Layer III frame
+// described by fi. See section 2.4.1.7 of ISO/IEC 11172-3.
+func parseSideInfo(b []byte, fi *FrameInfo) (*sideInfo, error) {
+	n := sideInfoLen(fi)
+	if len(b) < n {
+		return nil, fmt.Errorf("side info needs %d bytes but only %d available", n, len(b))
+	}
+	br := bitReader{b: b[:n]}
+
+	nch := fi.channels()
+	var si sideInfo
+	var ngr, granuleBits int
+	if fi.Version == Version1 {
+		si.mainDataBegin = int(br.read(9))
+		if nch == 1 {
+			br.skip(5) // private bits
+		} else {
+			br.skip(3) // private bits
+		}
+		br.skip(4 * nch) // scfsi
+		ngr, granuleBits = 2, 59
+	} else {
+		si.mainDataBegin = int(br.read(8))
+		br.skip(nch) // private bits
+		ngr, granuleBits = 1, 63
+	}
+
+	for gr := 0; gr < ngr; gr++ {
+		for ch := 0; ch < nch; ch++ {
+			si.part23Lengths = append(si.part23Lengths, int(br.read(12)))
+			br.skip(granuleBits - 12)
+		}
+	}
+	return &si, nil
+}
+
+// bitReader reads big-endian bit fields from a byte slice.
+// Reads past the end of the slice return zeros.
+type bitReader struct {
+	b   []byte
+	pos int // position in bits
+}
+
+// read returns the next n bits (up to 32).
+func (br *bitReader) read(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if idx := br.pos / 8; idx < len(br.b) {
+			v |= uint32(br.b[idx]>>(7-uint(br.pos%8))) & 0x1
+		}
+		br.pos++
+	}
+	return v
+}
+
+// skip advances past the next n bits.
+func (br *bitReader) skip(n int) { br.pos += n }
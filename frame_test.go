@@ -0,0 +1,58 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReadFrameType(t *testing.T) {
+	audio := makeFrame(t, testHeader128, 0)
+	audio[6] = 0x0f // set bits in first granule's part2_3_length
+
+	monoV2 := makeFrame(t, 0xfff390c0, 0) // MPEG-2 Layer III, 80 kbps, 22050 Hz, mono
+	monoV2[5] = 0x01                      // set bit in part2_3_length
+
+	l2Audio := makeFrame(t, 0xfffda400, 0)
+	l2Audio[100] = 0x12
+
+	for _, tc := range []struct {
+		desc string
+		data []byte
+		want FrameType
+	}{
+		{"audio", audio, AudioFrame},
+		{"empty", makeFrame(t, testHeader128, 0), EmptyFrame},
+		{"xing", makeXingFrame(t, 10, 4170, make([]byte, 100), nil), InfoFrame},
+		{"vbri", makeVBRIFrame(t, 10, 4170, 5, []uint16{1, 2}), InfoFrame},
+		{"v2 mono audio", monoV2, AudioFrame},
+		{"layer II audio", l2Audio, AudioFrame},
+		{"layer II empty", makeFrame(t, 0xfffda400, 0), EmptyFrame},
+	} {
+		r := bytes.NewReader(tc.data)
+		fi, err := ReadFrameInfoFrom(r, 0)
+		if err != nil {
+			t.Errorf("ReadFrameInfoFrom for %v failed: %v", tc.desc, err)
+			continue
+		}
+		if got, err := ReadFrameTypeFrom(r, 0, fi); err != nil {
+			t.Errorf("ReadFrameTypeFrom for %v failed: %v", tc.desc, err)
+		} else if got != tc.want {
+			t.Errorf("ReadFrameTypeFrom for %v returned %v; want %v", tc.desc, got, tc.want)
+		}
+	}
+
+	// Frames too short to hold their side info should be rejected rather than causing a panic.
+	for _, fi := range []*FrameInfo{
+		{Version: Version1, Layer: Layer3, SampleRate: 44100, SamplesPerFrame: 1152, FreeFormat: true, freeSlots: 10},
+		{Version: Version1, Layer: Layer2, SampleRate: 44100, SamplesPerFrame: 1152, FreeFormat: true, freeSlots: 5, HasCRC: true},
+		{Version: Version1, Layer: Layer3},
+	} {
+		r := bytes.NewReader(make([]byte, 100))
+		if got, err := ReadFrameTypeFrom(r, 0, fi); err == nil {
+			t.Errorf("ReadFrameTypeFrom for %d-byte %v frame unexpectedly returned %v", fi.Size(), fi.Layer, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ID3v1NoGenre is the ID3v1 genre index used to indicate that the genre is unset.
+const ID3v1NoGenre = 255
+
+// id3v1Genres contains ID3v1 genre names indexed by their numeric values.
+// Indexes 0-79 are defined by ID3v1 and the remainder are Winamp extensions.
+var id3v1Genres = [...]string{
+	// ID3v1
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+
+	// Winamp extensions
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebob", "Latin", "Revival",
+	"Celtic", "Bluegrass", "Avantgarde", "Gothic Rock", "Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech", "Chanson", "Opera",
+	"Chamber Music", "Sonata", "Symphony", "Booty Bass", "Primus", "Porn Groove", "Satire", "Slow Jam",
+	"Club", "Tango", "Samba", "Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle",
+	"Duet", "Punk Rock", "Drum Solo", "A capella", "Euro-House", "Dance Hall", "Goa", "Drum & Bass",
+	"Club-House", "Hardcore", "Terror", "Indie", "BritPop", "Afro-Punk", "Polsk Punk", "Beat",
+	"Christian Gangsta Rap", "Heavy Metal", "Black Metal", "Crossover", "Contemporary Christian", "Christian Rock", "Merengue", "Salsa",
+	"Thrash Metal", "Anime", "JPop", "Synthpop", "Abstract", "Art Rock", "Baroque", "Bhangra",
+	"Big Beat", "Breakbeat", "Chillout", "Downtempo", "Dub", "EBM", "Eclectic", "Electro",
+	"Electroclash", "Emo", "Experimental", "Garage", "Global", "IDM", "Illbient", "Industro-Goth",
+	"Jam Band", "Krautrock", "Leftfield", "Lounge", "Math Rock", "New Romantic", "Nu-Breakz", "Post-Punk",
+	"Post-Rock", "Psytrance", "Shoegaze", "Space Rock", "Trop Rock", "World Music", "Neoclassical", "Audiobook",
+	"Audio Theatre", "Neue Deutsche Welle", "Podcast", "Indie Rock", "G-Funk", "Dubstep", "Garage Rock", "Psybient",
+}
+
+// GenreName returns the name of the ID3v1 genre with the supplied index.
+// An empty string is returned if the index is ID3v1NoGenre or is unknown.
+func GenreName(idx byte) string {
+	if int(idx) < len(id3v1Genres) {
+		return id3v1Genres[idx]
+	}
+	return ""
+}
+
+// GenreIndex returns the index of the ID3v1 genre with the supplied case-insensitive name.
+// False is returned if the name is unknown.
+func GenreIndex(name string) (idx byte, ok bool) {
+	for i, g := range id3v1Genres {
+		if strings.EqualFold(g, name) {
+			return byte(i), true
+		}
+	}
+	return ID3v1NoGenre, false
+}
+
+// GenreName returns the name of the tag's genre, or an empty string if the genre is unset.
+func (tag *ID3v1Tag) GenreName() string { return GenreName(tag.Genre) }
+
+// ResolveID3v2Genre converts the value of an ID3v2 "TCON" frame to human-readable genres.
+// ID3v2.3 numeric references like "(17)" are replaced by the corresponding ID3v1 genre
+// names, and a value may contain multiple references (e.g. "(17)(80)"). A refinement
+// following a reference (e.g. "(4)Eurodisco") replaces it. ID3v2.4 bare numeric values
+// like "17" and the "RX" (remix) and "CR" (cover) keywords are also handled. Other values
+// are returned unchanged. ID3v2.4 frames containing multiple null-separated values should
+// have each value resolved individually (see GetID3v2TextFrameValues).
+func ResolveID3v2Genre(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if name, ok := resolveGenreRef(s); ok {
+		return []string{name}
+	}
+
+	var names []string
+	rest := s
+	for strings.HasPrefix(rest, "(") && !strings.HasPrefix(rest, "((") {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			break
+		}
+		n, ok := resolveGenreRef(rest[1:end])
+		if !ok {
+			break
+		}
+		names, rest = append(names, n), rest[end+1:]
+	}
+	if rest == "" {
+		return names
+	}
+	// Per ID3v2.3, a refinement starting with '(' is escaped as "((".
+	if strings.HasPrefix(rest, "((") {
+		rest = rest[1:]
+	}
+	if len(names) > 0 {
+		names = names[:len(names)-1]
+	}
+	return append(names, rest)
+}
+
+// resolveGenreRef resolves s, an ID3v1 genre index or ID3v2 genre keyword, to a name.
+func resolveGenreRef(s string) (string, bool) {
+	switch s {
+	case "RX":
+		return "Remix", true
+	case "CR":
+		return "Cover", true
+	}
+	if idx, err := strconv.ParseUint(s, 10, 8); err == nil {
+		if name := GenreName(byte(idx)); name != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
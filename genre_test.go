@@ -0,0 +1,66 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenreName(t *testing.T) {
+	if n := len(id3v1Genres); n != 192 {
+		t.Errorf("id3v1Genres has %d entries; want 192", n)
+	}
+	for _, tc := range []struct {
+		idx  byte
+		name string
+	}{
+		{0, "Blues"},
+		{17, "Rock"},
+		{79, "Hard Rock"},
+		{80, "Folk"},
+		{191, "Psybient"},
+		{192, ""},
+		{ID3v1NoGenre, ""},
+	} {
+		if got := GenreName(tc.idx); got != tc.name {
+			t.Errorf("GenreName(%v) = %q; want %q", tc.idx, got, tc.name)
+		}
+		if tc.name == "" {
+			continue
+		}
+		if got, ok := GenreIndex(strings.ToUpper(tc.name)); !ok || got != tc.idx {
+			t.Errorf("GenreIndex(%q) = %v, %v; want %v, true", strings.ToUpper(tc.name), got, ok, tc.idx)
+		}
+	}
+	if got, ok := GenreIndex("Bogus"); ok || got != ID3v1NoGenre {
+		t.Errorf("GenreIndex(%q) = %v, %v; want %v, false", "Bogus", got, ok, ID3v1NoGenre)
+	}
+}
+
+func TestResolveID3v2Genre(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"Rock", []string{"Rock"}},
+		{"(17)", []string{"Rock"}},
+		{"17", []string{"Rock"}},
+		{"(4)Eurodisco", []string{"Eurodisco"}},
+		{"(17)(80)", []string{"Rock", "Folk"}},
+		{"(17)(4)Eurodisco", []string{"Rock", "Eurodisco"}},
+		{"(RX)", []string{"Remix"}},
+		{"CR", []string{"Cover"}},
+		{"((Foo) Bar", []string{"(Foo) Bar"}},
+		{"(17)((Foo)", []string{"(Foo)"}},
+		{"(255)", []string{"(255)"}},
+		{"(abc", []string{"(abc"}},
+		{"", nil},
+	} {
+		if got := ResolveID3v2Genre(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ResolveID3v2Genre(%q) = %q; want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ID3v1Tag contains information from an ID3v1 footer at the end of an MP3 file.
+// ID3v1 is a terrible format: https://id3.org/ID3v1
+type ID3v1Tag struct {
+	Title, Artist, Album, Year, Comment string
+	Genre, Track                        byte
+
+	// Enhanced is true if the tag is accompanied by an Enhanced ("TAG+") block
+	// preceding it. If so, Title, Artist, and Album may be up to 90 characters long.
+	// See https://en.wikipedia.org/wiki/ID3#Enhanced_tag.
+	Enhanced bool
+	// The remaining fields are only stored in the Enhanced block.
+	Speed              ID3v1Speed
+	GenreText          string        // free-form genre
+	StartTime, EndTime time.Duration // start and end of music
+}
+
+// ID3v1Length is the length in bytes of an ID3v1 tag.
+const ID3v1Length = 128
+
+// ID3v1EnhancedLength is the length in bytes of an Enhanced ("TAG+") block.
+const ID3v1EnhancedLength = 227
+
+const (
+	id3v1Magic      = "TAG"
+	id3v1TitleLen   = 30
+	id3v1ArtistLen  = 30
+	id3v1AlbumLen   = 30
+	id3v1YearLen    = 4
+	id3v1CommentLen = 30
+	id3v1GenreLen   = 1
+
+	id3v1EnhMagic     = "TAG+"
+	id3v1EnhTitleLen  = 60
+	id3v1EnhArtistLen = 60
+	id3v1EnhAlbumLen  = 60
+	id3v1EnhSpeedLen  = 1
+	id3v1EnhGenreLen  = 30
+	id3v1EnhTimeLen   = 6
+)
+
+// ID3v1Speed describes the speed of the music in an Enhanced ID3v1 block.
+type ID3v1Speed byte
+
+const (
+	SpeedUnset    ID3v1Speed = 0
+	SpeedSlow     ID3v1Speed = 1
+	SpeedMedium   ID3v1Speed = 2
+	SpeedFast     ID3v1Speed = 3
+	SpeedHardcore ID3v1Speed = 4
+)
+
+func (s ID3v1Speed) String() string {
+	switch s {
+	case SpeedUnset:
+		return "unset"
+	case SpeedSlow:
+		return "slow"
+	case SpeedMedium:
+		return "medium"
+	case SpeedFast:
+		return "fast"
+	case SpeedHardcore:
+		return "hardcore"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(s))
+	}
+}
+
+// ReadID3v1Footer reads an ID3v1 footer from the final ID3v1Length bytes of f.
+// If the footer is preceded by an Enhanced ("TAG+") block, it is also read.
+// If the tag isn't present, the returned tag and error will be nil.
+// The tag's Size method returns the total length of the footer.
+// Text fields are decoded as ISO-8859-1.
+func ReadID3v1Footer(f *os.File, fi os.FileInfo) (*ID3v1Tag, error) {
+	return ReadID3v1FooterFrom(f, fi.Size())
+}
+
+// ReadID3v1FooterFrom is similar to ReadID3v1Footer but reads from the end of r,
+// which contains size bytes.
+func ReadID3v1FooterFrom(r io.ReaderAt, size int64) (*ID3v1Tag, error) {
+	return ReadID3v1FooterDecoded(r, size, Latin1)
+}
+
+// ReadID3v1FooterDecoded is similar to ReadID3v1FooterFrom but uses dec to convert
+// text fields to UTF-8. Although ID3v1 specifies ISO-8859-1, many taggers used the
+// system's legacy character set instead.
+func ReadID3v1FooterDecoded(r io.ReaderAt, size int64, dec TextDecoder) (*ID3v1Tag, error) {
+	// Check for an ID3v1 footer.
+	buf := make([]byte, ID3v1Length)
+	if _, err := r.ReadAt(buf, size-int64(len(buf))); err != nil {
+		return nil, err
+	}
+	b := bytes.NewBuffer(buf)
+	if string(b.Next(len(id3v1Magic))) != id3v1Magic {
+		return nil, nil
+	}
+
+	var decErr error // first error returned by dec
+	trim := func(b []byte) []byte { return bytes.TrimRight(b, "\x00") }
+	clean := func(b []byte) string {
+		s, err := dec.Bytes(trim(b))
+		if err != nil && decErr == nil {
+			decErr = err
+		}
+		return string(bytes.TrimSpace(s))
+	}
+
+	tag := &ID3v1Tag{}
+	result := func() (*ID3v1Tag, error) {
+		if decErr != nil {
+			return nil, decErr
+		}
+		return tag, nil
+	}
+
+	title := b.Next(id3v1TitleLen)
+	artist := b.Next(id3v1ArtistLen)
+	album := b.Next(id3v1AlbumLen)
+	tag.Title = clean(title)
+	tag.Artist = clean(artist)
+	tag.Album = clean(album)
+	tag.Year = clean(b.Next(id3v1YearLen))
+	comment := b.Next(id3v1CommentLen)
+	tag.Genre = b.Next(id3v1GenreLen)[0]
+
+	// ID3v1.1 extension: if the last byte of the comment field is non-zero but the byte before it
+	// is zero, then the last byte holds the track number.
+	idx1, idx2 := len(comment)-1, len(comment)-2
+	if comment[idx1] != 0x0 && comment[idx2] == 0x0 {
+		tag.Track = comment[idx1]
+		comment[idx1] = 0x0
+	}
+	tag.Comment = clean(comment)
+
+	// Check for an Enhanced block before the footer.
+	if size < ID3v1Length+ID3v1EnhancedLength {
+		return result()
+	}
+	buf = make([]byte, ID3v1EnhancedLength)
+	if _, err := r.ReadAt(buf, size-ID3v1Length-int64(len(buf))); err != nil {
+		return nil, err
+	}
+	b = bytes.NewBuffer(buf)
+	if string(b.Next(len(id3v1EnhMagic))) != id3v1EnhMagic {
+		return result()
+	}
+	tag.Enhanced = true
+
+	// The Enhanced block's fields hold continuations of the ID3v1 fields.
+	// Use the untrimmed ID3v1 data in case the text was split at a space.
+	extend := func(v1 []byte, ext []byte) string {
+		if ext = trim(ext); len(ext) == 0 {
+			return clean(v1)
+		}
+		return clean(append(append([]byte{}, trim(v1)...), ext...))
+	}
+	tag.Title = extend(title, b.Next(id3v1EnhTitleLen))
+	tag.Artist = extend(artist, b.Next(id3v1EnhArtistLen))
+	tag.Album = extend(album, b.Next(id3v1EnhAlbumLen))
+	tag.Speed = ID3v1Speed(b.Next(id3v1EnhSpeedLen)[0])
+	tag.GenreText = clean(b.Next(id3v1EnhGenreLen))
+	tag.StartTime = parseID3v1Time(clean(b.Next(id3v1EnhTimeLen)))
+	tag.EndTime = parseID3v1Time(clean(b.Next(id3v1EnhTimeLen)))
+
+	return result()
+}
+
+// Size returns the length in bytes of the tag when written to the end of a file:
+// ID3v1Length, plus ID3v1EnhancedLength if an Enhanced block is needed.
+func (tag *ID3v1Tag) Size() int64 {
+	if tag.needsEnhanced() {
+		return ID3v1Length + ID3v1EnhancedLength
+	}
+	return ID3v1Length
+}
+
+// needsEnhanced returns true if tag should be written with an Enhanced block.
+func (tag *ID3v1Tag) needsEnhanced() bool {
+	return tag.Enhanced ||
+		len(encodeLatin1(tag.Title)) > id3v1TitleLen ||
+		len(encodeLatin1(tag.Artist)) > id3v1ArtistLen ||
+		len(encodeLatin1(tag.Album)) > id3v1AlbumLen ||
+		tag.Speed != SpeedUnset || tag.GenreText != "" || tag.StartTime != 0 || tag.EndTime != 0
+}
+
+// MarshalBinary encodes tag as an ID3v1Length-byte ID3v1 tag, or as an ID3v1.1 tag
+// if Track is non-zero. If Enhanced is true or any fields only fit in an Enhanced block,
+// the returned data is prefixed by an ID3v1EnhancedLength-byte Enhanced block.
+// Fields are encoded as ISO-8859-1, with unrepresentable characters replaced by '?',
+// and are truncated if they're too long.
+func (tag *ID3v1Tag) MarshalBinary() ([]byte, error) {
+	title := encodeLatin1(tag.Title)
+	artist := encodeLatin1(tag.Artist)
+	album := encodeLatin1(tag.Album)
+
+	b := make([]byte, 0, tag.Size())
+	if tag.needsEnhanced() {
+		b = append(b, id3v1EnhMagic...)
+		b = appendID3v1Field(b, sliceFrom(title, id3v1TitleLen), id3v1EnhTitleLen)
+		b = appendID3v1Field(b, sliceFrom(artist, id3v1ArtistLen), id3v1EnhArtistLen)
+		b = appendID3v1Field(b, sliceFrom(album, id3v1AlbumLen), id3v1EnhAlbumLen)
+		b = append(b, byte(tag.Speed))
+		b = appendID3v1Field(b, encodeLatin1(tag.GenreText), id3v1EnhGenreLen)
+		b = appendID3v1Field(b, formatID3v1Time(tag.StartTime), id3v1EnhTimeLen)
+		b = appendID3v1Field(b, formatID3v1Time(tag.EndTime), id3v1EnhTimeLen)
+	}
+
+	b = append(b, id3v1Magic...)
+	b = appendID3v1Field(b, title, id3v1TitleLen)
+	b = appendID3v1Field(b, artist, id3v1ArtistLen)
+	b = appendID3v1Field(b, album, id3v1AlbumLen)
+	b = appendID3v1Field(b, encodeLatin1(tag.Year), id3v1YearLen)
+	if tag.Track != 0 {
+		// ID3v1.1: the last two bytes of the comment field hold a zero byte and the track number.
+		b = appendID3v1Field(b, encodeLatin1(tag.Comment), id3v1CommentLen-2)
+		b = append(b, 0x0, tag.Track)
+	} else {
+		b = appendID3v1Field(b, encodeLatin1(tag.Comment), id3v1CommentLen)
+	}
+	b = append(b, tag.Genre)
+	return b, nil
+}
+
+// encodeLatin1 encodes s as ISO-8859-1, replacing unrepresentable characters with '?'.
+func encodeLatin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, ch := range s {
+		if ch > 0xff {
+			ch = '?'
+		}
+		b = append(b, byte(ch))
+	}
+	return b
+}
+
+// appendID3v1Field appends field to b as an n-byte field, truncating field
+// if needed and padding it with zero bytes.
+func appendID3v1Field(b []byte, field []byte, n int) []byte {
+	if len(field) > n {
+		field = field[:n]
+	}
+	b = append(b, field...)
+	for i := len(field); i < n; i++ {
+		b = append(b, 0x0)
+	}
+	return b
+}
+
+// sliceFrom returns b[start:], or nil if b is shorter than start.
+func sliceFrom(b []byte, start int) []byte {
+	if len(b) <= start {
+		return nil
+	}
+	return b[start:]
+}
+
+// parseID3v1Time parses an Enhanced block time string like "123:45".
+// 0 is returned if the string is invalid.
+func parseID3v1Time(s string) time.Duration {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0
+	}
+	min, err := strconv.Atoi(parts[0])
+	if err != nil || min < 0 {
+		return 0
+	}
+	sec, err := strconv.Atoi(parts[1])
+	if err != nil || sec < 0 || sec > 59 {
+		return 0
+	}
+	return time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+}
+
+// formatID3v1Time formats d as an Enhanced block time string like "123:45".
+// Nil is returned if d is zero.
+func formatID3v1Time(d time.Duration) []byte {
+	if d <= 0 {
+		return nil
+	}
+	min, sec := int(d/time.Minute), int(d%time.Minute/time.Second)
+	if min > 999 {
+		min, sec = 999, 59
+	}
+	return []byte(fmt.Sprintf("%03d:%02d", min, sec))
+}
+
+// WriteID3v1Footer writes tag to the end of f, replacing the existing ID3v1 footer
+// (including any Enhanced block) if present.
+func WriteID3v1Footer(f *os.File, tag *ID3v1Tag) error {
+	b, err := tag.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	n, err := id3v1FooterLen(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	off := fi.Size() - n
+	if _, err := f.WriteAt(b, off); err != nil {
+		return err
+	}
+	// Drop any leftover data if the new footer is shorter than the old one.
+	if end := off + int64(len(b)); end < fi.Size() {
+		return f.Truncate(end)
+	}
+	return nil
+}
+
+// RemoveID3v1Footer truncates f to remove its ID3v1 footer (including any Enhanced block).
+// If f doesn't have an ID3v1 footer, it is left unchanged.
+func RemoveID3v1Footer(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	n, err := id3v1FooterLen(f, fi.Size())
+	if err != nil || n == 0 {
+		return err
+	}
+	return f.Truncate(fi.Size() - n)
+}
+
+// id3v1FooterLen returns the length of the ID3v1 tag (including any Enhanced block)
+// at the end of r, which contains size bytes. 0 is returned if there's no tag.
+func id3v1FooterLen(r io.ReaderAt, size int64) (int64, error) {
+	readMagic := func(off int64, magic string) (bool, error) {
+		if off < 0 {
+			return false, nil
+		}
+		b := make([]byte, len(magic))
+		if _, err := r.ReadAt(b, off); err != nil {
+			return false, err
+		}
+		return string(b) == magic, nil
+	}
+	if ok, err := readMagic(size-ID3v1Length, id3v1Magic); err != nil || !ok {
+		return 0, err
+	}
+	if ok, err := readMagic(size-ID3v1Length-ID3v1EnhancedLength, id3v1EnhMagic); err != nil {
+		return 0, err
+	} else if ok {
+		return ID3v1Length + ID3v1EnhancedLength, nil
+	}
+	return ID3v1Length, nil
+}
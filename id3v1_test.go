@@ -0,0 +1,229 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makeID3v1Footer returns an ID3v1Length-byte ID3v1.1 footer containing the supplied values.
+func makeID3v1Footer(title, artist, album, year, comment string, track, genre byte) []byte {
+	b := make([]byte, ID3v1Length)
+	copy(b, "TAG")
+	copy(b[3:33], title)
+	copy(b[33:63], artist)
+	copy(b[63:93], album)
+	copy(b[93:97], year)
+	copy(b[97:125], comment)
+	b[126] = track
+	b[127] = genre
+	return b
+}
+
+// makeID3v1Enhanced returns an ID3v1EnhancedLength-byte Enhanced ("TAG+") block
+// containing the supplied values.
+func makeID3v1Enhanced(title, artist, album string, speed byte, genre, start, end string) []byte {
+	b := make([]byte, ID3v1EnhancedLength)
+	copy(b, "TAG+")
+	copy(b[4:64], title)
+	copy(b[64:124], artist)
+	copy(b[124:184], album)
+	b[184] = speed
+	copy(b[185:215], genre)
+	copy(b[215:221], start)
+	copy(b[221:227], end)
+	return b
+}
+
+func TestReadID3v1FooterFrom(t *testing.T) {
+	data := append(bytes.Repeat([]byte{0xff}, 200),
+		makeID3v1Footer("Title", "Artist", "Album", "2022", "Comment", 7, 17)...)
+	tag, err := ReadID3v1FooterFrom(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal("ReadID3v1FooterFrom failed: ", err)
+	}
+	want := ID3v1Tag{
+		Title:   "Title",
+		Artist:  "Artist",
+		Album:   "Album",
+		Year:    "2022",
+		Comment: "Comment",
+		Genre:   17,
+		Track:   7,
+	}
+	if tag == nil || *tag != want {
+		t.Errorf("ReadID3v1FooterFrom returned %+v; want %+v", tag, want)
+	}
+
+	data = bytes.Repeat([]byte{0xff}, 200)
+	if tag, err := ReadID3v1FooterFrom(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Error("ReadID3v1FooterFrom failed without tag: ", err)
+	} else if tag != nil {
+		t.Errorf("ReadID3v1FooterFrom returned %+v without tag", tag)
+	}
+}
+
+func TestReadID3v1FooterDecoded(t *testing.T) {
+	data := makeID3v1Footer("\xcf\xf0\xe8\xe2\xe5\xf2", "Caf\xe9", "", "", "", 0, 0)
+	for _, tc := range []struct {
+		desc          string
+		dec           TextDecoder
+		title, artist string
+	}{
+		{"Latin1", Latin1, "Ïðèâåò", "Café"},
+		{"Windows1251", Windows1251, "Привет", "Cafй"},
+		{"AutoDetect", AutoDetect, "Привет", "Café"},
+	} {
+		if tag, err := ReadID3v1FooterDecoded(bytes.NewReader(data), int64(len(data)), tc.dec); err != nil {
+			t.Errorf("ReadID3v1FooterDecoded with %v failed: %v", tc.desc, err)
+		} else if tag.Title != tc.title || tag.Artist != tc.artist {
+			t.Errorf("ReadID3v1FooterDecoded with %v returned title %q and artist %q; want %q and %q",
+				tc.desc, tag.Title, tag.Artist, tc.title, tc.artist)
+		}
+	}
+}
+
+func TestReadID3v1FooterFrom_Enhanced(t *testing.T) {
+	title := "A title that is split between the ID3v1 tag and the Enhanced block"
+	data := bytes.Join([][]byte{
+		bytes.Repeat([]byte{0xff}, 200),
+		makeID3v1Enhanced(title[30:], "Artist", "", 2, "Eurodisco", "002:05", "bogus"),
+		makeID3v1Footer(title[:30], "Artist", "Album", "2022", "", 0, 255),
+	}, nil)
+	tag, err := ReadID3v1FooterFrom(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal("ReadID3v1FooterFrom failed: ", err)
+	}
+	want := ID3v1Tag{
+		Title:     title,
+		Artist:    "ArtistArtist",
+		Album:     "Album",
+		Year:      "2022",
+		Genre:     255,
+		Enhanced:  true,
+		Speed:     SpeedMedium,
+		GenreText: "Eurodisco",
+		StartTime: 125 * time.Second,
+	}
+	if tag == nil || *tag != want {
+		t.Errorf("ReadID3v1FooterFrom returned %+v; want %+v", tag, want)
+	} else if got, want := tag.Size(), int64(ID3v1Length+ID3v1EnhancedLength); got != want {
+		t.Errorf("Size() = %v; want %v", got, want)
+	}
+}
+
+func TestID3v1Tag_MarshalBinary(t *testing.T) {
+	for _, tc := range []struct {
+		tag  ID3v1Tag
+		want []byte
+	}{
+		{
+			ID3v1Tag{Title: "Title", Artist: "Artist", Album: "Album", Year: "2022", Comment: "Comment", Track: 7, Genre: 17},
+			makeID3v1Footer("Title", "Artist", "Album", "2022", "Comment", 7, 17),
+		},
+		{
+			// Overlong fields should be truncated, and non-Latin-1 characters should be replaced.
+			ID3v1Tag{
+				Title:   "Ünïcödé → title that is longer than thirty bytes",
+				Artist:  "Artist",
+				Year:    "20221",
+				Comment: "This comment is exactly 30 ch.",
+			},
+			func() []byte {
+				b := makeID3v1Footer("\xdcn\xefc\xf6d\xe9 ? title that is longer", "Artist", "", "2022", "", 0, 0)
+				copy(b[97:127], "This comment is exactly 30 ch.")
+				return append(makeID3v1Enhanced(" than thirty bytes", "", "", 0, "", "", ""), b...)
+			}(),
+		},
+		{
+			ID3v1Tag{
+				Title:     strings.Repeat("a", 30) + strings.Repeat("b", 60) + "c",
+				Speed:     SpeedFast,
+				GenreText: "Eurodisco",
+				StartTime: 90 * time.Second,
+				EndTime:   1000 * time.Minute,
+			},
+			append(makeID3v1Enhanced(strings.Repeat("b", 60), "", "", 3, "Eurodisco", "001:30", "999:59"),
+				makeID3v1Footer(strings.Repeat("a", 30), "", "", "", "", 0, 0)...),
+		},
+		{
+			ID3v1Tag{Title: "Title", Enhanced: true},
+			append(makeID3v1Enhanced("", "", "", 0, "", "", ""),
+				makeID3v1Footer("Title", "", "", "", "", 0, 0)...),
+		},
+	} {
+		if got, err := tc.tag.MarshalBinary(); err != nil {
+			t.Errorf("MarshalBinary() for %+v failed: %v", tc.tag, err)
+		} else if !bytes.Equal(got, tc.want) {
+			t.Errorf("MarshalBinary() for %+v = %q; want %q", tc.tag, got, tc.want)
+		}
+		if got, want := tc.tag.Size(), int64(len(tc.want)); got != want {
+			t.Errorf("Size() for %+v = %v; want %v", tc.tag, got, want)
+		}
+	}
+}
+
+func TestWriteID3v1Footer(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xff}, 200)
+	p := filepath.Join(t.TempDir(), "test.mp3")
+	if err := ioutil.WriteFile(p, audio, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(p, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	check := func(want *ID3v1Tag) {
+		t.Helper()
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantSize := int64(len(audio))
+		if want != nil {
+			wantSize += want.Size()
+		}
+		if fi.Size() != wantSize {
+			t.Errorf("File is %d bytes; want %d", fi.Size(), wantSize)
+		}
+		if tag, err := ReadID3v1Footer(f, fi); err != nil {
+			t.Error("ReadID3v1Footer failed: ", err)
+		} else if (tag == nil) != (want == nil) || (tag != nil && *tag != *want) {
+			t.Errorf("ReadID3v1Footer returned %+v; want %+v", tag, want)
+		}
+	}
+
+	tag1 := ID3v1Tag{Title: "Title", Artist: "Artist", Album: "Album", Year: "2022", Track: 3, Genre: 17}
+	if err := WriteID3v1Footer(f, &tag1); err != nil {
+		t.Fatal("WriteID3v1Footer failed: ", err)
+	}
+	check(&tag1)
+
+	tag2 := ID3v1Tag{Title: "New Title", Comment: "Comment", Genre: 255, Enhanced: true, Speed: SpeedSlow}
+	if err := WriteID3v1Footer(f, &tag2); err != nil {
+		t.Fatal("WriteID3v1Footer failed: ", err)
+	}
+	check(&tag2)
+
+	tag3 := ID3v1Tag{Title: "Final Title"}
+	if err := WriteID3v1Footer(f, &tag3); err != nil {
+		t.Fatal("WriteID3v1Footer failed: ", err)
+	}
+	check(&tag3)
+
+	for i := 0; i < 2; i++ {
+		if err := RemoveID3v1Footer(f); err != nil {
+			t.Fatal("RemoveID3v1Footer failed: ", err)
+		}
+		check(nil)
+	}
+}
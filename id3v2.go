@@ -0,0 +1,656 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ID3v2Tag contains an ID3v2.2, v2.3, or v2.4 tag read by ReadID3v2Tag.
+// It implements taglib.GenericTag, so it may be passed to functions like
+// GetID3v2TextFrame and GetID3v2Time.
+type ID3v2Tag struct {
+	// Version contains the tag's major version: 2, 3, or 4.
+	Version int
+	// Revision contains the tag's revision number, typically 0.
+	Revision int
+	// Size contains the tag's total length in bytes, including its header and footer.
+	Size int64
+
+	Unsynchronised bool // tag-level unsynchronisation flag is set
+	ExtendedHeader bool // tag has an extended header
+	Experimental   bool // tag is marked as experimental
+	Footer         bool // v2.4 tag has a footer
+
+	// Frames contains the tag's frames in the order in which they appear.
+	Frames []*ID3v2Frame
+}
+
+// ID3v2Frame contains a single frame from an ID3v2 tag.
+type ID3v2Frame struct {
+	// ID contains the frame's four-character ID, e.g. "TIT2". Three-character ID3v2.2 IDs
+	// are mapped to their ID3v2.4 equivalents, e.g. "TT2" becomes "TIT2". Date frames
+	// ("TYE", "TDA", "TIM", "TOR", and "TRD") and other frames without v2.4 equivalents
+	// (e.g. "TSI") are mapped to their ID3v2.3 IDs, e.g. "TYE" becomes "TYER". "PIC" data
+	// is converted to the "APIC" format, but other data is not, so "RVA" and "EQU" frames
+	// mapped to "RVA2" and "EQU2" still use the ID3v2.2 format.
+	ID string
+	// RawID contains the frame's ID as it appears in the tag.
+	RawID string
+	// Data contains the frame's contents. Unsynchronisation and compression have been
+	// undone, and extra header data like group IDs and data length indicators has been
+	// removed. Encrypted frames' contents are returned unmodified.
+	Data []byte
+
+	Compressed bool // frame was compressed with zlib
+	Encrypted  bool // frame is encrypted with EncryptionMethod
+	Grouped    bool // frame belongs to the group with GroupID
+
+	EncryptionMethod byte
+	GroupID          byte
+}
+
+const (
+	id3v2Magic       = "ID3"
+	id3v2FooterMagic = "3DI"
+	id3v2HeaderLen   = 10 // length of header and footer
+
+	id3v2FlagUnsync       = 0x80
+	id3v2FlagExtHeader    = 0x40 // v2.3 and v2.4 only
+	id3v2FlagCompressed   = 0x40 // v2.2 only
+	id3v2FlagExperimental = 0x20
+	id3v2FlagFooter       = 0x10 // v2.4: tag is followed by a footer
+)
+
+// id3v2Header contains information from an ID3v2 tag's header or footer.
+// See section 3.1 of https://id3.org/id3v2.4.0-structure.
+type id3v2Header struct {
+	major, minor byte  // e.g. 4 and 0 for v2.4.0
+	flags        byte  // tag-level flags
+	size         int64 // length of the tag excluding its header and footer
+}
+
+// parseID3v2Header parses b, which contains an ID3v2 header (if magic is id3v2Magic)
+// or footer (if magic is id3v2FooterMagic). False is returned if b isn't a valid header.
+func parseID3v2Header(b []byte, magic string) (id3v2Header, bool) {
+	if len(b) < id3v2HeaderLen || string(b[:3]) != magic {
+		return id3v2Header{}, false
+	}
+	if b[3] == 0xff || b[4] == 0xff {
+		return id3v2Header{}, false
+	}
+	size, ok := syncsafe(b[6:10])
+	if !ok {
+		return id3v2Header{}, false
+	}
+	return id3v2Header{major: b[3], minor: b[4], flags: b[5], size: int64(size)}, true
+}
+
+// totalLen returns the tag's total length, including its header and footer.
+func (h *id3v2Header) totalLen() int64 {
+	n := id3v2HeaderLen + h.size
+	if h.major >= 4 && h.flags&id3v2FlagFooter != 0 {
+		n += id3v2HeaderLen
+	}
+	return n
+}
+
+// syncsafe decodes b as a big-endian "synchsafe" integer, in which the high bit
+// of each byte is zero. False is returned if a high bit is set.
+func syncsafe(b []byte) (uint32, bool) {
+	var v uint32
+	for _, ch := range b {
+		if ch&0x80 != 0 {
+			return 0, false
+		}
+		v = v<<7 | uint32(ch)
+	}
+	return v, true
+}
+
+// removeUnsync reverses the unsynchronisation scheme by dropping each zero byte
+// that follows a 0xff byte. See section 6.1 of https://id3.org/id3v2.4.0-structure.
+func removeUnsync(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for i, ch := range b {
+		if ch == 0 && i > 0 && b[i-1] == 0xff {
+			continue
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// ReadID3v2Tag reads an ID3v2 tag from the beginning of f.
+// If the tag isn't present, the returned tag and error will be nil.
+func ReadID3v2Tag(f *os.File, fi os.FileInfo) (*ID3v2Tag, error) {
+	return ReadID3v2TagFrom(f, fi.Size(), 0)
+}
+
+// ReadID3v2TagFrom is similar to ReadID3v2Tag but reads the tag starting at off in r,
+// which contains size bytes. Stacked and appended tags can be read using the offsets
+// returned by ReadTagLayout.
+func ReadID3v2TagFrom(r io.ReaderAt, size, off int64) (*ID3v2Tag, error) {
+	b := make([]byte, id3v2HeaderLen)
+	if _, err := r.ReadAt(b, off); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	hdr, ok := parseID3v2Header(b, id3v2Magic)
+	if !ok {
+		return nil, nil
+	}
+	if hdr.major < 2 || hdr.major > 4 {
+		return nil, fmt.Errorf("unsupported ID3 version 2.%d", hdr.major)
+	}
+	// Check the size before allocating a buffer for the tag's body.
+	if off+hdr.totalLen() > size {
+		return nil, fmt.Errorf("ID3v2 tag at %#x extends past end of file", off)
+	}
+
+	tag := ID3v2Tag{
+		Version:        int(hdr.major),
+		Revision:       int(hdr.minor),
+		Size:           hdr.totalLen(),
+		Unsynchronised: hdr.flags&id3v2FlagUnsync != 0,
+		Experimental:   hdr.flags&id3v2FlagExperimental != 0,
+		Footer:         hdr.major >= 4 && hdr.flags&id3v2FlagFooter != 0,
+	}
+	if hdr.major == 2 && hdr.flags&id3v2FlagCompressed != 0 {
+		// No compression scheme was ever defined for v2.2.
+		return nil, errors.New("compressed ID3v2.2 tags are unsupported")
+	}
+	tag.ExtendedHeader = hdr.major >= 3 && hdr.flags&id3v2FlagExtHeader != 0
+
+	body := make([]byte, hdr.size)
+	if _, err := r.ReadAt(body, off+id3v2HeaderLen); err != nil {
+		return nil, err
+	}
+	// In v2.4, unsynchronisation is applied to each frame individually.
+	if tag.Unsynchronised && tag.Version < 4 {
+		body = removeUnsync(body)
+	}
+
+	if tag.ExtendedHeader {
+		var n int64
+		if len(body) < 4 {
+			return nil, errors.New("truncated extended header")
+		}
+		if tag.Version == 3 {
+			// The v2.3 size excludes the size field itself.
+			n = int64(binary.BigEndian.Uint32(body)) + 4
+		} else if v, ok := syncsafe(body[:4]); ok {
+			n = int64(v)
+		} else {
+			return nil, errors.New("invalid extended header size")
+		}
+		if n > int64(len(body)) {
+			return nil, fmt.Errorf("%d-byte extended header extends past end of tag", n)
+		}
+		body = body[n:]
+	}
+
+	for len(body) > 0 {
+		frame, n, err := tag.parseFrame(body)
+		if err != nil {
+			return nil, err
+		} else if frame == nil {
+			break // padding
+		}
+		tag.Frames = append(tag.Frames, frame)
+		body = body[n:]
+	}
+	return &tag, nil
+}
+
+// parseFrame parses the frame at the beginning of b and returns it and its total length.
+// A nil frame is returned if padding or an invalid frame ID is encountered.
+func (tag *ID3v2Tag) parseFrame(b []byte) (*ID3v2Frame, int, error) {
+	idLen, hdrLen := 4, 10
+	if tag.Version == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	if len(b) < hdrLen || !isID3v2FrameID(b[:idLen]) {
+		return nil, 0, nil
+	}
+
+	frame := ID3v2Frame{RawID: string(b[:idLen]), ID: string(b[:idLen])}
+	var size int
+	var flags uint16
+	switch tag.Version {
+	case 2:
+		size = int(b[3])<<16 | int(b[4])<<8 | int(b[5])
+		if id, ok := id3v22FrameIDs[frame.RawID]; ok {
+			frame.ID = id
+		}
+	case 3:
+		size = int(binary.BigEndian.Uint32(b[4:8]))
+		flags = binary.BigEndian.Uint16(b[8:10])
+	case 4:
+		// Some taggers (notably older versions of iTunes) incorrectly
+		// wrote v2.4 frame sizes as regular big-endian integers. Use the
+		// syncsafe size unless only the big-endian size leads to another frame.
+		size = int(binary.BigEndian.Uint32(b[4:8]))
+		if v, ok := syncsafe(b[4:8]); ok &&
+			(isID3v2FrameEnd(b, hdrLen+int(v), idLen) || !isID3v2FrameEnd(b, hdrLen+size, idLen)) {
+			size = int(v)
+		}
+		flags = binary.BigEndian.Uint16(b[8:10])
+	}
+	if size > len(b)-hdrLen {
+		return nil, 0, fmt.Errorf("frame %q extends past end of tag", frame.RawID)
+	}
+	data := b[hdrLen : hdrLen+size]
+
+	// Strip the extra data that the flags add to the beginning of the frame.
+	// See section 3.3.1 of https://id3.org/id3v2.3.0 and section 4.1.2 of
+	// https://id3.org/id3v2.4.0-structure.
+	next := func(n int) ([]byte, error) {
+		if len(data) < n {
+			return nil, fmt.Errorf("frame %q too short for flags", frame.RawID)
+		}
+		v := data[:n]
+		data = data[n:]
+		return v, nil
+	}
+	var unsync, hasDataLen bool
+	var err error
+	switch tag.Version {
+	case 3:
+		frame.Compressed = flags&0x0080 != 0
+		frame.Encrypted = flags&0x0040 != 0
+		frame.Grouped = flags&0x0020 != 0
+		if frame.Compressed {
+			_, err = next(4) // decompressed size
+		}
+		if err == nil && frame.Encrypted {
+			var v []byte
+			v, err = next(1)
+			if err == nil {
+				frame.EncryptionMethod = v[0]
+			}
+		}
+		if err == nil && frame.Grouped {
+			var v []byte
+			v, err = next(1)
+			if err == nil {
+				frame.GroupID = v[0]
+			}
+		}
+	case 4:
+		frame.Grouped = flags&0x0040 != 0
+		frame.Compressed = flags&0x0008 != 0
+		frame.Encrypted = flags&0x0004 != 0
+		unsync = flags&0x0002 != 0 || tag.Unsynchronised
+		hasDataLen = flags&0x0001 != 0
+		if frame.Grouped {
+			var v []byte
+			v, err = next(1)
+			if err == nil {
+				frame.GroupID = v[0]
+			}
+		}
+		if err == nil && frame.Encrypted {
+			var v []byte
+			v, err = next(1)
+			if err == nil {
+				frame.EncryptionMethod = v[0]
+			}
+		}
+		if err == nil && hasDataLen {
+			_, err = next(4)
+		}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if unsync {
+		data = removeUnsync(data)
+	}
+	if frame.Compressed && !frame.Encrypted {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, 0, fmt.Errorf("frame %q: %v", frame.RawID, err)
+		}
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return nil, 0, fmt.Errorf("frame %q: %v", frame.RawID, err)
+		}
+	}
+	if tag.Version == 2 && frame.RawID == "PIC" {
+		data = convertID3v22Picture(data)
+	}
+	frame.Data = data
+	return &frame, hdrLen + size, nil
+}
+
+// isID3v2FrameEnd returns true if a frame ending at end in b is followed by
+// the end of b, padding, or another frame's idLen-byte ID.
+func isID3v2FrameEnd(b []byte, end, idLen int) bool {
+	switch {
+	case end > len(b):
+		return false
+	case end == len(b) || b[end] == 0:
+		return true
+	case end+idLen > len(b):
+		return false
+	default:
+		return isID3v2FrameID(b[end : end+idLen])
+	}
+}
+
+// convertID3v22Picture converts the data from an ID3v2.2 "PIC" frame to the "APIC" format
+// by replacing the three-character image format (e.g. "JPG") with a MIME type.
+// See section 4.15 of https://id3.org/id3v2-00 and section 4.14 of https://id3.org/id3v2.4.0-frames.
+func convertID3v22Picture(b []byte) []byte {
+	if len(b) < 4 {
+		return b
+	}
+	var mime string
+	switch format := string(b[1:4]); strings.ToUpper(format) {
+	case "JPG":
+		mime = "image/jpeg"
+	case "-->": // data contains a URL
+		mime = format
+	default:
+		mime = "image/" + strings.ToLower(strings.TrimRight(format, " \x00"))
+	}
+	out := make([]byte, 0, len(b)+len(mime))
+	out = append(out, b[0])
+	out = append(out, mime...)
+	out = append(out, 0)
+	return append(out, b[4:]...)
+}
+
+// isID3v2FrameID returns true if b contains only uppercase letters and digits.
+func isID3v2FrameID(b []byte) bool {
+	for _, ch := range b {
+		if (ch < 'A' || ch > 'Z') && (ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// id3v22FrameIDs maps from ID3v2.2 frame IDs to the corresponding ID3v2.4 IDs.
+// Frames that were dropped in v2.4 ("TDA", "TIM", "TRD", and "TSI") are mapped to ID3v2.3 IDs,
+// as are "TYE" and "TOR" so that they remain consistent with the other date frames.
+// See https://id3.org/id3v2-00, section 4 of https://id3.org/id3v2.3.0, and section 4 of
+// https://id3.org/id3v2.4.0-changes.
+var id3v22FrameIDs = map[string]string{
+	"BUF": "RBUF",
+	"CNT": "PCNT",
+	"COM": "COMM",
+	"CRA": "AENC",
+	"EQU": "EQU2",
+	"ETC": "ETCO",
+	"GEO": "GEOB",
+	"IPL": "TIPL",
+	"LNK": "LINK",
+	"MCI": "MCDI",
+	"MLL": "MLLT",
+	"PIC": "APIC",
+	"POP": "POPM",
+	"REV": "RVRB",
+	"RVA": "RVA2",
+	"SLT": "SYLT",
+	"STC": "SYTC",
+	"TAL": "TALB",
+	"TBP": "TBPM",
+	"TCM": "TCOM",
+	"TCO": "TCON",
+	"TCP": "TCMP",
+	"TCR": "TCOP",
+	"TDA": "TDAT",
+	"TDY": "TDLY",
+	"TEN": "TENC",
+	"TFT": "TFLT",
+	"TIM": "TIME",
+	"TKE": "TKEY",
+	"TLA": "TLAN",
+	"TLE": "TLEN",
+	"TMT": "TMED",
+	"TOA": "TOPE",
+	"TOF": "TOFN",
+	"TOL": "TOLY",
+	"TOR": "TORY",
+	"TOT": "TOAL",
+	"TP1": "TPE1",
+	"TP2": "TPE2",
+	"TP3": "TPE3",
+	"TP4": "TPE4",
+	"TPA": "TPOS",
+	"TPB": "TPUB",
+	"TRC": "TSRC",
+	"TRD": "TRDA",
+	"TRK": "TRCK",
+	"TS2": "TSO2",
+	"TSA": "TSOA",
+	"TSC": "TSOC",
+	"TSI": "TSIZ",
+	"TSP": "TSOP",
+	"TSS": "TSSE",
+	"TST": "TSOT",
+	"TT1": "TIT1",
+	"TT2": "TIT2",
+	"TT3": "TIT3",
+	"TXT": "TEXT",
+	"TXX": "TXXX",
+	"TYE": "TYER",
+	"UFI": "UFID",
+	"ULT": "USLT",
+	"WAF": "WOAF",
+	"WAR": "WOAR",
+	"WAS": "WOAS",
+	"WCM": "WCOM",
+	"WCP": "WCOP",
+	"WPB": "WPUB",
+	"WXX": "WXXX",
+}
+
+// Find returns all frames with the supplied ID.
+func (tag *ID3v2Tag) Find(id string) []*ID3v2Frame {
+	var frames []*ID3v2Frame
+	for _, f := range tag.Frames {
+		if f.ID == id {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// Text decodes the frame's contents as a text information frame, i.e. a text encoding
+// byte followed by one or more null-separated strings. For "TXXX" frames, the first
+// string contains the description.
+func (f *ID3v2Frame) Text() ([]string, error) {
+	if f.Encrypted {
+		return nil, fmt.Errorf("frame %q is encrypted", f.RawID)
+	}
+	if len(f.Data) == 0 {
+		return nil, fmt.Errorf("frame %q is empty", f.RawID)
+	}
+	return decodeID3v2Text(f.Data[0], f.Data[1:])
+}
+
+// ID3v2 text encodings.
+const (
+	id3v2Latin1  = 0
+	id3v2UTF16   = 1 // with byte order mark
+	id3v2UTF16BE = 2 // v2.4 only
+	id3v2UTF8    = 3 // v2.4 only
+)
+
+// decodeID3v2Text decodes b, containing null-separated strings in the supplied encoding.
+// A trailing null terminator is ignored.
+func decodeID3v2Text(enc byte, b []byte) ([]string, error) {
+	var vals []string
+	switch enc {
+	case id3v2Latin1, id3v2UTF8:
+		b = bytes.TrimSuffix(b, []byte{0})
+		for _, p := range bytes.Split(b, []byte{0}) {
+			if enc == id3v2Latin1 {
+				p, _ = Latin1.Bytes(p)
+			}
+			vals = append(vals, string(p))
+		}
+	case id3v2UTF16, id3v2UTF16BE:
+		if len(b)%2 != 0 {
+			b = b[:len(b)-1]
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		if n := len(units); n > 0 && units[n-1] == 0 {
+			units = units[:n-1]
+		}
+		// Each string may start with its own byte order mark.
+		bigEndian := true
+		start := 0
+		for i := 0; i <= len(units); i++ {
+			if i < len(units) && units[i] != 0 {
+				continue
+			}
+			s := units[start:i]
+			if len(s) > 0 && s[0] == 0xfeff {
+				s, bigEndian = s[1:], true
+			} else if len(s) > 0 && s[0] == 0xfffe {
+				s, bigEndian = s[1:], false
+			}
+			if !bigEndian {
+				swapped := make([]uint16, len(s))
+				for j, u := range s {
+					swapped[j] = u<<8 | u>>8
+				}
+				s = swapped
+			}
+			vals = append(vals, string(utf16.Decode(s)))
+			start = i + 1
+		}
+	default:
+		return nil, fmt.Errorf("invalid text encoding %d", enc)
+	}
+	return vals, nil
+}
+
+// textValue returns the first value from the first frame with the supplied ID,
+// or an empty string if the frame isn't present or can't be decoded.
+func (tag *ID3v2Tag) textValue(id string) string {
+	frames := tag.Find(id)
+	if len(frames) == 0 {
+		return ""
+	}
+	vals, err := frames[0].Text()
+	if err != nil || len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+// Title returns the "TIT2" frame's value.
+func (tag *ID3v2Tag) Title() string { return tag.textValue("TIT2") }
+
+// Artist returns the "TPE1" frame's value.
+func (tag *ID3v2Tag) Artist() string { return tag.textValue("TPE1") }
+
+// Album returns the "TALB" frame's value.
+func (tag *ID3v2Tag) Album() string { return tag.textValue("TALB") }
+
+// Comment returns the text of the first "COMM" frame.
+func (tag *ID3v2Tag) Comment() string {
+	for _, f := range tag.Find("COMM") {
+		// COMM frames contain an encoding byte, a three-byte language,
+		// a null-terminated description, and the text.
+		if f.Encrypted || len(f.Data) < 4 {
+			continue
+		}
+		if vals, err := decodeID3v2Text(f.Data[0], f.Data[4:]); err == nil && len(vals) >= 2 {
+			return vals[1]
+		}
+	}
+	return ""
+}
+
+// Genre returns the "TCON" frames' values with ID3v1 genre references resolved.
+// Multiple genres are separated by commas.
+func (tag *ID3v2Tag) Genre() string {
+	var genres []string
+	for _, f := range tag.Find("TCON") {
+		vals, _ := f.Text()
+		for _, v := range vals {
+			genres = append(genres, ResolveID3v2Genre(v)...)
+		}
+	}
+	return strings.Join(genres, ", ")
+}
+
+// Year returns the year from the "TDRC" frame (or from the "TYER" frame in ID3v2.2 and v2.3 tags).
+func (tag *ID3v2Tag) Year() time.Time {
+	s := tag.textValue("TDRC")
+	if s == "" {
+		s = tag.textValue("TYER")
+	}
+	if len(s) < 4 {
+		return time.Time{}
+	}
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Track returns the track number from the "TRCK" frame.
+func (tag *ID3v2Tag) Track() uint32 { return parseLeadingUint(tag.textValue("TRCK")) }
+
+// Disc returns the disc number from the "TPOS" frame.
+func (tag *ID3v2Tag) Disc() uint32 { return parseLeadingUint(tag.textValue("TPOS")) }
+
+// parseLeadingUint parses the number at the beginning of s, e.g. 3 for "3/12".
+// 0 is returned if s doesn't start with a number.
+func parseLeadingUint(s string) uint32 {
+	if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		s = s[:i]
+	}
+	v, _ := strconv.ParseUint(s, 10, 32)
+	return uint32(v)
+}
+
+// UniqueFileIdentifiers returns a map from owners to identifiers from "UFID" frames.
+func (tag *ID3v2Tag) UniqueFileIdentifiers() map[string]string {
+	ids := make(map[string]string)
+	for _, f := range tag.Find("UFID") {
+		// UFID frames contain a null-terminated owner followed by a binary identifier.
+		if i := bytes.IndexByte(f.Data, 0); i >= 0 && !f.Encrypted {
+			ids[string(f.Data[:i])] = string(f.Data[i+1:])
+		}
+	}
+	return ids
+}
+
+// CustomFrames returns a map from descriptions to values from "TXXX" frames.
+func (tag *ID3v2Tag) CustomFrames() map[string]string {
+	info := make(map[string]string)
+	for _, f := range tag.Find("TXXX") {
+		if vals, err := f.Text(); err == nil && len(vals) >= 2 {
+			info[vals[0]] = vals[1]
+		}
+	}
+	return info
+}
+
+// TagSize returns the tag's total length in bytes.
+func (tag *ID3v2Tag) TagSize() uint32 { return uint32(tag.Size) }
@@ -0,0 +1,286 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeID3v2Shell returns an ID3v2 tag with the supplied major version, flags, and body.
+// If the footer flag is set, the tag is followed by an ID3v2.4 footer.
+func makeID3v2Shell(major, flags byte, body []byte) []byte {
+	makeHeader := func(magic string) []byte {
+		b := []byte{magic[0], magic[1], magic[2], major, 0, flags}
+		return append(b, syncsafeBytes(len(body))...)
+	}
+	b := append(makeHeader(id3v2Magic), body...)
+	if flags&id3v2FlagFooter != 0 {
+		b = append(b, makeHeader(id3v2FooterMagic)...)
+	}
+	return b
+}
+
+// syncsafeBytes returns n encoded as a 4-byte synchsafe integer.
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// addUnsync applies the unsynchronisation scheme to b by adding a zero byte after each 0xff byte.
+func addUnsync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff}, []byte{0xff, 0})
+}
+
+// makeID3v2Frame returns a frame for an ID3v2 tag with the supplied major version.
+// The flags are ignored for v2.2. If syncsafe is false for a v2.4 frame, the size
+// is written as a regular big-endian integer.
+func makeID3v2Frame(major byte, id string, flags uint16, data []byte, syncsafe bool) []byte {
+	b := []byte(id)
+	switch {
+	case major == 2:
+		n := len(data)
+		b = append(b, byte(n>>16), byte(n>>8), byte(n))
+		return append(b, data...)
+	case major == 4 && syncsafe:
+		b = append(b, syncsafeBytes(len(data))...)
+	default:
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[4:], uint32(len(data)))
+	}
+	b = append(b, byte(flags>>8), byte(flags))
+	return append(b, data...)
+}
+
+// utf16Text returns s encoded as UTF-16 with a byte order mark.
+func utf16Text(s string, bigEndian bool) []byte {
+	var b []byte
+	put := func(u uint16) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	put(0xfeff)
+	for _, r := range s {
+		put(uint16(r))
+	}
+	return b
+}
+
+func TestReadID3v2TagFrom_V22(t *testing.T) {
+	var body []byte
+	add := func(id string, data []byte) { body = append(body, makeID3v2Frame(2, id, 0, data, false)...) }
+	add("TT2", []byte("\x00Title"))
+	add("TP1", append(append([]byte{id3v2UTF16}, utf16Text("Artïst", false)...), 0, 0))
+	add("TAL", []byte("\x00Alb\xfcm\x00"))
+	add("TYE", []byte("\x002001"))
+	add("TDA", []byte("\x000323"))
+	add("TIM", []byte("\x000506"))
+	add("TOR", []byte("\x001999"))
+	add("TRK", []byte("\x003/12"))
+	add("TCO", []byte("\x00(17)"))
+	add("COM", []byte("\x00engdesc\x00Comment"))
+	add("PIC", []byte("\x00JPG\x03\x00image"))
+	body = append(body, make([]byte, 100)...) // padding
+	data := append([]byte("junk"), makeID3v2Shell(2, 0, body)...)
+
+	tag, err := ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 4)
+	if err != nil {
+		t.Fatal("ReadID3v2TagFrom failed: ", err)
+	}
+	if tag.Version != 2 || tag.Size != int64(len(data)-4) {
+		t.Errorf("ReadID3v2TagFrom returned version %d and size %d; want 2 and %d", tag.Version, tag.Size, len(data)-4)
+	}
+	var ids []string
+	for _, f := range tag.Frames {
+		ids = append(ids, f.RawID+"/"+f.ID)
+	}
+	if want := []string{"TT2/TIT2", "TP1/TPE1", "TAL/TALB", "TYE/TYER", "TDA/TDAT", "TIM/TIME",
+		"TOR/TORY", "TRK/TRCK", "TCO/TCON", "COM/COMM", "PIC/APIC"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ReadID3v2TagFrom returned frames %q; want %q", ids, want)
+	}
+
+	for _, tc := range []struct {
+		fn   string
+		got  interface{}
+		want interface{}
+	}{
+		{"Title", tag.Title(), "Title"},
+		{"Artist", tag.Artist(), "Artïst"},
+		{"Album", tag.Album(), "Albüm"},
+		{"Comment", tag.Comment(), "Comment"},
+		{"Genre", tag.Genre(), "Rock"},
+		{"Year", tag.Year(), time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"Track", tag.Track(), uint32(3)},
+		{"Disc", tag.Disc(), uint32(0)},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%v() = %q; want %q", tc.fn, tc.got, tc.want)
+		}
+	}
+
+	if got, err := GetID3v2TextFrame(tag, "TPE1"); err != nil || got != "Artïst" {
+		t.Errorf("GetID3v2TextFrame(tag, %q) = %q, %v; want %q, nil", "TPE1", got, err, "Artïst")
+	}
+	for id, want := range map[string]string{"TYER": "2001", "TDAT": "0323", "TIME": "0506", "TORY": "1999"} {
+		if got, err := GetID3v2TextFrame(tag, id); err != nil || got != want {
+			t.Errorf("GetID3v2TextFrame(tag, %q) = %q, %v; want %q, nil", id, got, err, want)
+		}
+	}
+	if got, err := GetID3v2Time(tag, RecordingTime); err != nil ||
+		got.Year() != 2001 || got.Month() != 3 || got.Day() != 23 || got.Hour() != 5 || got.Minute() != 6 {
+		t.Errorf("GetID3v2Time(tag, RecordingTime) = %v, %v; want 2001-03-23T05:06", got.String(), err)
+	}
+	if got, err := GetID3v2Time(tag, OriginalReleaseTime); err != nil || got.Year() != 1999 {
+		t.Errorf("GetID3v2Time(tag, OriginalReleaseTime) = %v, %v; want 1999", got, err)
+	}
+	if got, want := string(tag.Find("APIC")[0].Data), "\x00image/jpeg\x00\x03\x00image"; got != want {
+		t.Errorf("APIC data = %q; want %q", got, want)
+	}
+}
+
+func TestReadID3v2TagFrom_V23(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("\x00Compressed Album"))
+	zw.Close()
+
+	body := []byte{0, 0, 0, 6, 0, 0, 0, 0, 0, 0} // extended header
+	add := func(id string, flags uint16, data []byte) {
+		body = append(body, makeID3v2Frame(3, id, flags, data, false)...)
+	}
+	add("TIT2", 0, []byte("\x00T\xfftle"))
+	add("TALB", 0x0080, append([]byte{0, 0, 0, 17}, compressed.Bytes()...))
+	add("TPE1", 0x0020, []byte("\x05\x00Grouped Artist"))
+	add("TCOM", 0x0040, []byte("\x80secret"))
+	add("TXXX", 0, []byte("\x00MusicBrainz Album Id\x00abc-123"))
+	add("UFID", 0, []byte("http://musicbrainz.org\x00xyz"))
+	data := makeID3v2Shell(3, id3v2FlagUnsync|id3v2FlagExtHeader, addUnsync(body))
+
+	tag, err := ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 0)
+	if err != nil {
+		t.Fatal("ReadID3v2TagFrom failed: ", err)
+	}
+	if !tag.Unsynchronised || !tag.ExtendedHeader {
+		t.Errorf("ReadID3v2TagFrom returned unsync %v and extended header %v; want true and true",
+			tag.Unsynchronised, tag.ExtendedHeader)
+	}
+	if got, want := tag.Title(), "Tÿtle"; got != want {
+		t.Errorf("Title() = %q; want %q", got, want)
+	}
+	if got, want := tag.Album(), "Compressed Album"; got != want {
+		t.Errorf("Album() = %q; want %q", got, want)
+	}
+	if f := tag.Find("TPE1"); len(f) != 1 || !f[0].Grouped || f[0].GroupID != 5 {
+		t.Errorf("Find(%q) returned %+v; want grouped frame", "TPE1", f)
+	} else if got, want := tag.Artist(), "Grouped Artist"; got != want {
+		t.Errorf("Artist() = %q; want %q", got, want)
+	}
+	if f := tag.Find("TCOM"); len(f) != 1 || !f[0].Encrypted || f[0].EncryptionMethod != 0x80 {
+		t.Errorf("Find(%q) returned %+v; want encrypted frame", "TCOM", f)
+	} else if _, err := f[0].Text(); err == nil {
+		t.Error("Text() unexpectedly succeeded for encrypted frame")
+	}
+	if got, want := tag.CustomFrames(), map[string]string{"MusicBrainz Album Id": "abc-123"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CustomFrames() = %q; want %q", got, want)
+	}
+	if got, want := tag.UniqueFileIdentifiers(), map[string]string{"http://musicbrainz.org": "xyz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UniqueFileIdentifiers() = %q; want %q", got, want)
+	}
+}
+
+func TestReadID3v2TagFrom_V24(t *testing.T) {
+	body := []byte{0, 0, 0, 6, 1, 0} // extended header
+	add := func(id string, flags uint16, data []byte, syncsafe bool) {
+		body = append(body, makeID3v2Frame(4, id, flags, data, syncsafe)...)
+	}
+	title := []byte("\x03Title \xc3\xbf\xff\xe0")
+	add("TIT2", 0x0003, append(syncsafeBytes(len(title)), addUnsync(title)...), true)
+	add("TPE1", 0, []byte("\x03Artist 1\x00Artist 2\x00"), true)
+	add("TCON", 0, append([]byte{id3v2UTF16BE}, 0, '1', 0, '7', 0, 0, 0, '(', 0, '8', 0, '0', 0, ')'), true)
+	long := bytes.Repeat([]byte{'x'}, 200)
+	add("TXXX", 0, append([]byte("\x00Long\x00"), long...), false) // iTunes-style size
+	ambig := bytes.Repeat([]byte{'y'}, 250)
+	add("TXXX", 0, append([]byte("\x00Ambig\x00"), ambig...), false) // 0x101 is also a valid syncsafe int
+	safe := bytes.Repeat([]byte{'z'}, 300)
+	add("TXXX", 0, append([]byte("\x00Safe\x00"), safe...), true)
+	add("TRCK", 0, []byte("\x007"), true)
+	data := makeID3v2Shell(4, id3v2FlagExtHeader|id3v2FlagFooter, body)
+
+	tag, err := ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 0)
+	if err != nil {
+		t.Fatal("ReadID3v2TagFrom failed: ", err)
+	}
+	if !tag.Footer || tag.Size != int64(len(data)) {
+		t.Errorf("ReadID3v2TagFrom returned footer %v and size %d; want true and %d", tag.Footer, tag.Size, len(data))
+	}
+	if got, want := tag.Title(), "Title ÿ\xff\xe0"; got != want {
+		t.Errorf("Title() = %q; want %q", got, want)
+	}
+	if vals, err := tag.Find("TPE1")[0].Text(); err != nil {
+		t.Error("Text() failed for TPE1: ", err)
+	} else if want := []string{"Artist 1", "Artist 2"}; !reflect.DeepEqual(vals, want) {
+		t.Errorf("Text() for TPE1 = %q; want %q", vals, want)
+	}
+	if got, want := tag.Genre(), "Rock, Folk"; got != want {
+		t.Errorf("Genre() = %q; want %q", got, want)
+	}
+	for desc, want := range map[string][]byte{"Long": long, "Ambig": ambig, "Safe": safe} {
+		if got := tag.CustomFrames()[desc]; got != string(want) {
+			t.Errorf("CustomFrames()[%q] = %q; want %q", desc, got, want)
+		}
+	}
+	if got, want := tag.Track(), uint32(7); got != want {
+		t.Errorf("Track() = %v; want %v", got, want)
+	}
+}
+
+func TestReadID3v2TagFrom_Missing(t *testing.T) {
+	data := bytes.Repeat(makeFrame(t, testHeader128, 0), 2)
+	if tag, err := ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 0); err != nil || tag != nil {
+		t.Errorf("ReadID3v2TagFrom = %+v, %v; want nil, nil", tag, err)
+	}
+}
+
+func TestReadID3v2TagFrom_Truncated(t *testing.T) {
+	// The header claims a body of nearly 256 MB.
+	data := []byte("ID3\x03\x00\x00\x7f\x7f\x7f\x7f")
+	if tag, err := ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 0); err == nil {
+		t.Errorf("ReadID3v2TagFrom = %+v; want error", tag)
+	}
+	data = makeID3v2Shell(3, 0, makeID3v2Frame(3, "TIT2", 0, []byte("\x00Title"), false))
+	if tag, err := ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)-1), 0); err == nil {
+		t.Errorf("ReadID3v2TagFrom = %+v; want error for short source", tag)
+	}
+}
+
+func TestDecodeID3v2Text(t *testing.T) {
+	for _, tc := range []struct {
+		enc  byte
+		in   []byte
+		want []string
+	}{
+		{id3v2Latin1, []byte("Caf\xe9\x00"), []string{"Café"}},
+		{id3v2Latin1, []byte("A\x00B"), []string{"A", "B"}},
+		{id3v2UTF8, []byte("Caf\xc3\xa9\x00Tea"), []string{"Café", "Tea"}},
+		{id3v2UTF16, append(append(utf16Text("Ab", false), 0, 0), utf16Text("Cd", true)...), []string{"Ab", "Cd"}},
+		{id3v2UTF16, append(utf16Text("Ab", false), 0, 0, 'C', 0), []string{"Ab", "C"}},
+		{id3v2UTF16BE, []byte{0, 'A', 0, 0, 0, 'B', 0, 0}, []string{"A", "B"}},
+		{id3v2UTF8, nil, []string{""}},
+	} {
+		if got, err := decodeID3v2Text(tc.enc, tc.in); err != nil {
+			t.Errorf("decodeID3v2Text(%v, %q) failed: %v", tc.enc, tc.in, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("decodeID3v2Text(%v, %q) = %q; want %q", tc.enc, tc.in, got, tc.want)
+		}
+	}
+	if _, err := decodeID3v2Text(4, []byte("abc")); err == nil {
+		t.Error("decodeID3v2Text unexpectedly succeeded for invalid encoding")
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// lameTagLen is the length in bytes of the LAME extension that follows an Xing header.
+const lameTagLen = 36
+
+// LAMEInfo contains information from the LAME extension to an Xing (or Info) header.
+// See http://gabriel.mp3-tech.org/mp3infotag.html.
+type LAMEInfo struct {
+	// Revision contains the info tag revision.
+	Revision int
+	// LowpassFreq contains the lowpass filter frequency in hertz.
+	LowpassFreq int
+	// Peak contains the peak signal amplitude, where 1.0 is the maximal amplitude.
+	// It is 0 if unknown.
+	Peak float64
+	// TrackGain contains the "radio" ReplayGain adjustment.
+	TrackGain ReplayGain
+	// AlbumGain contains the "audiophile" ReplayGain adjustment.
+	AlbumGain ReplayGain
+	// Flags contains encoding flags.
+	Flags LAMEFlags
+	// ATHType contains the absolute threshold of hearing type.
+	ATHType int
+	// Bitrate contains the specified bitrate in kbit/s for ABR files or the minimal bitrate otherwise.
+	// 255 indicates a bitrate of 255 kbit/s or greater.
+	Bitrate int
+	// EncoderDelay contains the number of samples of silence added to the beginning of the audio.
+	EncoderDelay int
+	// Padding contains the number of samples of silence added to the end of the audio.
+	Padding int
+	// NoiseShaping contains the noise shaping type.
+	NoiseShaping int
+	// StereoMode contains the stereo mode used for encoding.
+	StereoMode LAMEStereoMode
+	// Unwise is true if unwise settings were used.
+	Unwise bool
+	// SourceRate describes the sample rate of the source audio.
+	SourceRate LAMESourceRate
+	// MP3Gain contains the MP3Gain change applied to the file, in steps of 1.5 dB.
+	MP3Gain int
+	// Surround contains the surround encoding type.
+	Surround LAMESurround
+	// Preset contains the preset used for encoding. See PresetName.
+	Preset int
+	// MusicLength contains the length of the file in bytes when it was encoded,
+	// including the Xing frame but excluding any tags.
+	MusicLength uint32
+	// MusicCRC contains a CRC-16 of the audio frames following the Xing frame.
+	MusicCRC uint16
+	// TagCRC contains a CRC-16 of the Xing frame up to the TagCRC field
+	// (i.e. the first 190 bytes of an MPEG-1 stereo frame without a CRC).
+	TagCRC uint16
+}
+
+// parseLAMETag parses the lameTagLen-byte LAME extension in b.
+func parseLAMETag(b []byte) *LAMEInfo {
+	var info LAMEInfo
+	info.Revision = int(b[9] >> 4)
+	info.LowpassFreq = int(b[10]) * 100
+
+	// LAME writes the peak amplitude as a fixed-point value with 23 fractional bits
+	// rather than as the float described by the spec.
+	info.Peak = float64(binary.BigEndian.Uint32(b[11:15])) / (1 << 23)
+	info.TrackGain = parseReplayGain(binary.BigEndian.Uint16(b[15:17]))
+	info.AlbumGain = parseReplayGain(binary.BigEndian.Uint16(b[17:19]))
+
+	info.Flags = LAMEFlags(b[19] >> 4)
+	info.ATHType = int(b[19] & 0xf)
+	info.Bitrate = int(b[20])
+
+	// The delay and padding are packed into 24 bits.
+	info.EncoderDelay = int(b[21])<<4 | int(b[22]>>4)
+	info.Padding = int(b[22]&0xf)<<8 | int(b[23])
+
+	info.NoiseShaping = int(b[24] & 0x3)
+	info.StereoMode = LAMEStereoMode((b[24] >> 2) & 0x7)
+	info.Unwise = b[24]&0x20 != 0
+	info.SourceRate = LAMESourceRate(b[24] >> 6)
+	info.MP3Gain = int(int8(b[25]))
+
+	preset := binary.BigEndian.Uint16(b[26:28])
+	info.Surround = LAMESurround((preset >> 11) & 0x7)
+	info.Preset = int(preset & 0x7ff)
+
+	info.MusicLength = binary.BigEndian.Uint32(b[28:32])
+	info.MusicCRC = binary.BigEndian.Uint16(b[32:34])
+	info.TagCRC = binary.BigEndian.Uint16(b[34:36])
+	return &info
+}
+
+// PresetName returns a description of info.Preset, e.g. "V2" or "extreme".
+// An empty string is returned if no preset was used.
+func (info *LAMEInfo) PresetName() string {
+	switch p := info.Preset; {
+	case p == 0:
+		return ""
+	case p >= 8 && p <= 320:
+		return fmt.Sprintf("ABR %d", p)
+	case p >= 410 && p <= 500 && p%10 == 0:
+		return fmt.Sprintf("V%d", (500-p)/10)
+	default:
+		if s, ok := lamePresetNames[p]; ok {
+			return s
+		}
+		return fmt.Sprintf("unknown (%d)", p)
+	}
+}
+
+var lamePresetNames = map[int]string{
+	1000: "r3mix",
+	1001: "standard",
+	1002: "extreme",
+	1003: "insane",
+	1004: "standard fast",
+	1005: "extreme fast",
+	1006: "medium",
+	1007: "medium fast",
+}
+
+// ReplayGain contains a ReplayGain adjustment from a LAME tag.
+type ReplayGain struct {
+	// Name is 0 if the adjustment is unset, 1 for radio (track) gain, or 2 for audiophile (album) gain.
+	Name int
+	// Originator describes how the adjustment was set, e.g. 1 for the artist, 2 for the user,
+	// or 3 for the model.
+	Originator int
+	// Gain contains the adjustment in dB.
+	Gain float64
+}
+
+// Set returns true if g contains an adjustment.
+func (g ReplayGain) Set() bool { return g.Name != 0 }
+
+// parseReplayGain parses a 16-bit ReplayGain field from a LAME tag.
+func parseReplayGain(v uint16) ReplayGain {
+	g := ReplayGain{
+		Name:       int(v >> 13),
+		Originator: int((v >> 10) & 0x7),
+		Gain:       float64(v&0x1ff) / 10,
+	}
+	if v&0x200 != 0 {
+		g.Gain = -g.Gain
+	}
+	return g
+}
+
+// LAMEFlags contains encoding flags from a LAME tag.
+type LAMEFlags uint8
+
+const (
+	LAMENSPsyTune   LAMEFlags = 0x1 // --nspsytune
+	LAMENSSafeJoint LAMEFlags = 0x2 // --nssafejoint
+	LAMENoGapNext   LAMEFlags = 0x4 // --nogap continued in next track
+	LAMENoGapPrev   LAMEFlags = 0x8 // --nogap continued from previous track
+)
+
+var lameFlagNames = []struct {
+	flag LAMEFlags
+	name string
+}{
+	{LAMENSPsyTune, "nspsytune"},
+	{LAMENSSafeJoint, "nssafejoint"},
+	{LAMENoGapNext, "nogap-next"},
+	{LAMENoGapPrev, "nogap-prev"},
+}
+
+func (f LAMEFlags) String() string {
+	var names []string
+	for _, fn := range lameFlagNames {
+		if f&fn.flag != 0 {
+			names = append(names, fn.name)
+			f &^= fn.flag
+		}
+	}
+	if f != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint8(f)))
+	}
+	return strings.Join(names, "|")
+}
+
+// LAMEStereoMode describes the stereo mode used by LAME.
+type LAMEStereoMode int
+
+const (
+	LAMEMono LAMEStereoMode = iota
+	LAMEStereo
+	LAMEDual
+	LAMEJoint
+	LAMEForce
+	LAMEAuto
+	LAMEIntensity
+	LAMEUndefined
+)
+
+var lameStereoModeNames = map[LAMEStereoMode]string{
+	LAMEMono:      "mono",
+	LAMEStereo:    "stereo",
+	LAMEDual:      "dual",
+	LAMEJoint:     "joint",
+	LAMEForce:     "force",
+	LAMEAuto:      "auto",
+	LAMEIntensity: "intensity",
+	LAMEUndefined: "undefined",
+}
+
+func (m LAMEStereoMode) String() string {
+	if s, ok := lameStereoModeNames[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("invalid (%d)", int(m))
+}
+
+// LAMESourceRate describes the sample rate of the audio that was passed to LAME.
+type LAMESourceRate int
+
+const (
+	LAMESourceRate32    LAMESourceRate = iota // 32 kHz or less
+	LAMESourceRate44                          // 44.1 kHz
+	LAMESourceRate48                          // 48 kHz
+	LAMESourceRateAbove                       // above 48 kHz
+)
+
+var lameSourceRateNames = map[LAMESourceRate]string{
+	LAMESourceRate32:    "<= 32 kHz",
+	LAMESourceRate44:    "44.1 kHz",
+	LAMESourceRate48:    "48 kHz",
+	LAMESourceRateAbove: "> 48 kHz",
+}
+
+func (r LAMESourceRate) String() string {
+	if s, ok := lameSourceRateNames[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("invalid (%d)", int(r))
+}
+
+// LAMESurround describes the surround encoding used by LAME.
+type LAMESurround int
+
+const (
+	LAMENoSurround LAMESurround = iota
+	LAMEDPL
+	LAMEDPL2
+	LAMEAmbisonic
+)
+
+var lameSurroundNames = map[LAMESurround]string{
+	LAMENoSurround: "none",
+	LAMEDPL:        "DPL",
+	LAMEDPL2:       "DPL2",
+	LAMEAmbisonic:  "Ambisonic",
+}
+
+func (s LAMESurround) String() string {
+	if n, ok := lameSurroundNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("reserved (%d)", int(s))
+}
+
+// LAMECRCResult contains the results of verifying the CRCs in a LAME tag.
+type LAMECRCResult struct {
+	// StoredTagCRC and ComputedTagCRC contain the LAME tag's stored CRC and the CRC
+	// computed over the Xing frame up to the tag CRC field.
+	StoredTagCRC, ComputedTagCRC uint16
+	// StoredMusicCRC and ComputedMusicCRC contain the LAME tag's stored music CRC and the CRC
+	// computed over the audio frames following the Xing frame.
+	StoredMusicCRC, ComputedMusicCRC uint16
+	// StoredMusicLength contains the music length from the LAME tag, i.e. the number of bytes
+	// from the start of the Xing frame to the end of the audio data when the file was encoded.
+	StoredMusicLength int64
+	// ActualMusicLength contains the number of bytes from the start of the Xing frame
+	// to the end of the audio data.
+	ActualMusicLength int64
+}
+
+// TagOK returns true if the LAME tag's CRC is correct.
+func (res *LAMECRCResult) TagOK() bool { return res.StoredTagCRC == res.ComputedTagCRC }
+
+// MusicOK returns true if the music's length and CRC are unchanged since encoding.
+func (res *LAMECRCResult) MusicOK() bool {
+	return res.StoredMusicCRC == res.ComputedMusicCRC && res.StoredMusicLength == res.ActualMusicLength
+}
+
+// Truncated returns true if the audio data is shorter than when it was encoded.
+func (res *LAMECRCResult) Truncated() bool { return res.ActualMusicLength < res.StoredMusicLength }
+
+// lameTagCRCOffset is the offset of the tag CRC field within the LAME extension.
+// The CRC covers all preceding bytes in the Xing frame.
+const lameTagCRCOffset = 34
+
+// VerifyLAMECRCs recomputes the tag and music CRCs from the LAME tag in f to determine whether
+// the tag or audio have been altered or truncated since the file was encoded. headerLen and
+// footerLen are described by NewFrameScanner. An error is returned if the file doesn't have
+// a LAME tag.
+func VerifyLAMECRCs(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (*LAMECRCResult, error) {
+	return VerifyLAMECRCsFrom(f, fi.Size(), headerLen, footerLen)
+}
+
+// VerifyLAMECRCsFrom is similar to VerifyLAMECRCs but reads from r, which contains size bytes.
+func VerifyLAMECRCsFrom(r io.ReaderAt, size, headerLen, footerLen int64) (*LAMECRCResult, error) {
+	fstart, finfo, err := findFirstFrame(r, headerLen)
+	if err != nil {
+		return nil, err
+	}
+	vbrInfo, lameStart, err := readXingHeader(r, size, fstart, finfo)
+	if err != nil {
+		return nil, err
+	} else if vbrInfo == nil || vbrInfo.LAME == nil {
+		return nil, errors.New("no LAME tag")
+	}
+	lame := vbrInfo.LAME
+
+	res := LAMECRCResult{
+		StoredTagCRC:      lame.TagCRC,
+		StoredMusicCRC:    lame.MusicCRC,
+		StoredMusicLength: int64(lame.MusicLength),
+		ActualMusicLength: size - footerLen - fstart,
+	}
+
+	// The position of the tag CRC depends on the frame's side information and on which
+	// Xing fields are present.
+	b := make([]byte, lameStart+lameTagCRCOffset-fstart)
+	if _, err := r.ReadAt(b, fstart); err != nil {
+		return nil, err
+	}
+	res.ComputedTagCRC = updateLAMECRC(0, b)
+
+	// Compute the music CRC over the frames following the Xing frame, stopping at the
+	// original music length in case data has been appended.
+	mstart, mend := fstart+finfo.Size(), fstart+res.StoredMusicLength
+	if max := size - footerLen; mend > max {
+		mend = max
+	}
+	if mend > mstart {
+		sr := io.NewSectionReader(r, mstart, mend-mstart)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := sr.Read(buf)
+			res.ComputedMusicCRC = updateLAMECRC(res.ComputedMusicCRC, buf[:n])
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &res, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// testLAMETag is a LAME extension containing the values in testLAMEInfo.
+var testLAMETag = []byte{
+	'L', 'A', 'M', 'E', '3', '.', '9', '9', 'r', // encoder
+	0x13,                   // revision 1, VBR1
+	195,                    // lowpass
+	0x00, 0x40, 0x00, 0x00, // peak
+	0x2e, 0x41, // track gain
+	0x00, 0x00, // album gain
+	0x34,             // flags and ATH type
+	128,              // bitrate
+	0x24, 0x04, 0x50, // delay and padding
+	0x4d,       // misc
+	0xfe,       // MP3Gain
+	0x03, 0xe9, // surround and preset
+	0x00, 0x01, 0x23, 0x45, // music length
+	0xab, 0xcd, // music CRC
+	0x12, 0x34, // tag CRC
+}
+
+var testLAMEInfo = LAMEInfo{
+	Revision:     1,
+	LowpassFreq:  19500,
+	Peak:         0.5,
+	TrackGain:    ReplayGain{Name: 1, Originator: 3, Gain: -6.5},
+	Flags:        LAMENSPsyTune | LAMENSSafeJoint,
+	ATHType:      4,
+	Bitrate:      128,
+	EncoderDelay: 576,
+	Padding:      1104,
+	NoiseShaping: 1,
+	StereoMode:   LAMEJoint,
+	SourceRate:   LAMESourceRate44,
+	MP3Gain:      -2,
+	Preset:       1001,
+	MusicLength:  0x12345,
+	MusicCRC:     0xabcd,
+	TagCRC:       0x1234,
+}
+
+func TestComputeAudioDuration_LAME(t *testing.T) {
+	data := makeXingFrame(t, 10, 4170, make([]byte, 100), testLAMETag)
+	_, info, err := ComputeAudioDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+	if err != nil {
+		t.Fatal("ComputeAudioDurationFrom failed: ", err)
+	}
+	if info.Encoder != "LAME3.99r" || info.Method != VBR1 || info.Quality != 50 {
+		t.Errorf("ComputeAudioDurationFrom returned encoder %q, method %v, quality %v; want %q, %v, %v",
+			info.Encoder, info.Method, info.Quality, "LAME3.99r", VBR1, 50)
+	}
+	if info.LAME == nil || !reflect.DeepEqual(*info.LAME, testLAMEInfo) {
+		t.Errorf("ComputeAudioDurationFrom returned LAME info %+v; want %+v", info.LAME, testLAMEInfo)
+	}
+	if got, want := info.LAME.PresetName(), "standard"; got != want {
+		t.Errorf("PresetName() = %q; want %q", got, want)
+	}
+}
+
+func TestLAMEInfo_PresetName(t *testing.T) {
+	for _, tc := range []struct {
+		preset int
+		want   string
+	}{
+		{0, ""},
+		{128, "ABR 128"},
+		{500, "V0"},
+		{480, "V2"},
+		{410, "V9"},
+		{1002, "extreme"},
+		{2000, "unknown (2000)"},
+	} {
+		info := LAMEInfo{Preset: tc.preset}
+		if got := info.PresetName(); got != tc.want {
+			t.Errorf("PresetName() for %d = %q; want %q", tc.preset, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyLAMECRCs(t *testing.T) {
+	for _, hc := range []struct {
+		desc   string
+		header uint32
+	}{
+		{"MPEG-1 stereo", testHeader128},
+		{"MPEG-1 mono", 0xfffb90c0},
+		{"MPEG-2 stereo", 0xfff3c000},
+	} {
+		var audio []byte
+		for i := 0; i < 5; i++ {
+			audio = append(audio, makeFrame(t, hc.header, byte(i+1))...)
+		}
+		xing := makeXingFrameWithHeader(t, hc.header, 5, 0, make([]byte, 100), testLAMETag)
+		musicLen := uint32(len(xing) + len(audio))
+
+		// Find the LAME tag and fill in its length and CRC fields.
+		lameStart := bytes.Index(xing, testLAMETag[:9])
+		lame := xing[lameStart : lameStart+lameTagLen]
+		binary.BigEndian.PutUint32(lame[28:], musicLen)
+		binary.BigEndian.PutUint16(lame[32:], updateLAMECRC(0, audio))
+		crcStart := lameStart + lameTagCRCOffset
+		binary.BigEndian.PutUint16(xing[crcStart:], updateLAMECRC(0, xing[:crcStart]))
+		footer := makeID3v1Footer("Title", "Artist", "Album", "2022", "", 0, 0)
+
+		join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+		badXing := append([]byte{}, xing...)
+		badXing[100] ^= 0x1
+
+		for _, tc := range []struct {
+			desc                      string
+			data                      []byte
+			tagOK, musicOK, truncated bool
+		}{
+			{"good", join(xing, audio, footer), true, true, false},
+			{"truncated", join(xing, audio[:len(audio)-100], footer), true, false, true},
+			{"modified audio", join(xing, audio[:500], []byte{0}, audio[501:], footer), true, false, false},
+			{"appended", join(xing, audio, []byte("junk"), footer), true, false, false},
+			{"modified tag", join(badXing, audio, footer), false, true, false},
+		} {
+			res, err := VerifyLAMECRCsFrom(bytes.NewReader(tc.data), int64(len(tc.data)), 0, int64(len(footer)))
+			if err != nil {
+				t.Errorf("VerifyLAMECRCsFrom failed for %v %v: %v", hc.desc, tc.desc, err)
+				continue
+			}
+			if res.TagOK() != tc.tagOK || res.MusicOK() != tc.musicOK || res.Truncated() != tc.truncated {
+				t.Errorf("VerifyLAMECRCsFrom for %v %v returned tag %v, music %v, truncated %v; want %v, %v, %v",
+					hc.desc, tc.desc, res.TagOK(), res.MusicOK(), res.Truncated(),
+					tc.tagOK, tc.musicOK, tc.truncated)
+			}
+		}
+	}
+
+	data := append(makeXingFrame(t, 5, 0, make([]byte, 100), nil), makeFrame(t, testHeader128, 0)...)
+	if _, err := VerifyLAMECRCsFrom(bytes.NewReader(data), int64(len(data)), 0, 0); err == nil {
+		t.Error("VerifyLAMECRCsFrom unexpectedly succeeded without LAME tag")
+	}
+}
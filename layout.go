@@ -0,0 +1,214 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// TagLayout describes the locations of tags within a file.
+type TagLayout struct {
+	// Regions describes the tags in the order in which they appear in the file.
+	Regions []TagRegion
+	// AudioStart contains the offset of the first byte after all leading tags.
+	// It can be passed as headerLen to functions like ComputeAudioSHA1.
+	AudioStart int64
+	// AudioEnd contains the offset of the first byte of the trailing tags
+	// (or the file's size if there aren't any trailing tags).
+	AudioEnd int64
+	// Size contains the file's size.
+	Size int64
+}
+
+// HeaderLen returns the total length of the tags at the beginning of the file.
+func (l *TagLayout) HeaderLen() int64 { return l.AudioStart }
+
+// FooterLen returns the total length of the tags at the end of the file.
+func (l *TagLayout) FooterLen() int64 { return l.Size - l.AudioEnd }
+
+// TagRegion describes the location of a single tag within a file.
+type TagRegion struct {
+	// Type describes the tag's type.
+	Type TagType
+	// Offset contains the offset of the tag from the beginning of the file.
+	Offset int64
+	// Length contains the tag's length in bytes.
+	Length int64
+}
+
+// TagType describes the type of a TagRegion.
+type TagType int
+
+const (
+	// TagID3v2 is an ID3v2 tag, including its header and optional footer.
+	TagID3v2 TagType = iota
+	// TagID3v2Padding is zero-filled padding following an ID3v2 tag at the start of the file.
+	TagID3v2Padding
+	// TagID3v1 is a 128-byte ID3v1 tag.
+	TagID3v1
+	// TagID3v1Enhanced is a 227-byte Enhanced ID3v1 ("TAG+") block.
+	TagID3v1Enhanced
+	// TagAPE is an APEv1 or APEv2 tag, including its header and footer.
+	TagAPE
+	// TagLyrics3 is a Lyrics3 v1 or v2 block.
+	TagLyrics3
+)
+
+func (t TagType) String() string {
+	switch t {
+	case TagID3v2:
+		return "ID3v2"
+	case TagID3v2Padding:
+		return "ID3v2 padding"
+	case TagID3v1:
+		return "ID3v1"
+	case TagID3v1Enhanced:
+		return "ID3v1 enhanced"
+	case TagAPE:
+		return "APE"
+	case TagLyrics3:
+		return "Lyrics3"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(t))
+	}
+}
+
+// ReadTagLayout locates the tags at the beginning and end of f.
+//
+// Leading tags consist of one or more ID3v2 tags, each optionally followed by zero-filled
+// padding. Trailing tags may consist of ID3v1 (including Enhanced blocks), APE, Lyrics3,
+// and appended ID3v2.4 tags (which must have footers), in any order.
+func ReadTagLayout(f *os.File, fi os.FileInfo) (*TagLayout, error) {
+	return ReadTagLayoutFrom(f, fi.Size())
+}
+
+// ReadTagLayoutFrom is similar to ReadTagLayout but reads from r, which contains size bytes.
+func ReadTagLayoutFrom(r io.ReaderAt, size int64) (*TagLayout, error) {
+	layout := TagLayout{Size: size}
+	add := func(typ TagType, off, n int64) {
+		layout.Regions = append(layout.Regions, TagRegion{typ, off, n})
+	}
+
+	// Find ID3v2 tags and padding at the beginning of the file.
+	start := int64(0)
+	for {
+		b := make([]byte, id3v2HeaderLen)
+		if _, err := r.ReadAt(b, start); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		hdr, ok := parseID3v2Header(b, id3v2Magic)
+		if !ok {
+			break
+		}
+		n := hdr.totalLen()
+		if start+n > size {
+			return nil, fmt.Errorf("ID3v2 tag at %#x extends past end of file", start)
+		}
+		add(TagID3v2, start, n)
+		start += n
+
+		pad, err := countZeros(r, start, size)
+		if err != nil {
+			return nil, err
+		}
+		if pad > 0 {
+			add(TagID3v2Padding, start, pad)
+			start += pad
+		}
+	}
+
+	// Find tags at the end of the file, working backward.
+	end := size
+	for end > start {
+		if n, err := id3v1FooterLen(r, end); err != nil {
+			return nil, err
+		} else if n > 0 {
+			if n > ID3v1Length {
+				add(TagID3v1Enhanced, end-n, ID3v1EnhancedLength)
+			}
+			add(TagID3v1, end-ID3v1Length, ID3v1Length)
+			end -= n
+			continue
+		}
+		if tag, err := readAPETag(r, end); err != nil {
+			return nil, err
+		} else if tag != nil {
+			add(TagAPE, tag.Offset, tag.Length)
+			end = tag.Offset
+			continue
+		}
+		if tag, err := readLyrics3Tag(r, end); err != nil {
+			return nil, err
+		} else if tag != nil {
+			add(TagLyrics3, tag.Offset, tag.Length)
+			end = tag.Offset
+			continue
+		}
+		if n, err := readID3v2FooterLen(r, end); err != nil {
+			return nil, err
+		} else if n > 0 {
+			add(TagID3v2, end-n, n)
+			end -= n
+			continue
+		}
+		break
+	}
+	if end < start {
+		return nil, fmt.Errorf("trailing tags overlap leading tags at %#x", end)
+	}
+
+	sort.Slice(layout.Regions, func(i, j int) bool {
+		return layout.Regions[i].Offset < layout.Regions[j].Offset
+	})
+	layout.AudioStart, layout.AudioEnd = start, end
+	return &layout, nil
+}
+
+// countZeros returns the number of consecutive zero bytes starting at off in r,
+// which contains size bytes.
+func countZeros(r io.ReaderAt, off, size int64) (int64, error) {
+	var n int64
+	b := make([]byte, 4096)
+	for off+n < size {
+		if rem := size - off - n; rem < int64(len(b)) {
+			b = b[:rem]
+		}
+		if _, err := r.ReadAt(b, off+n); err != nil {
+			return 0, err
+		}
+		for _, ch := range b {
+			if ch != 0 {
+				return n, nil
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// readID3v2FooterLen checks for an ID3v2.4 footer ending at end in r and returns
+// the total length of the corresponding tag. 0 is returned if there's no footer.
+func readID3v2FooterLen(r io.ReaderAt, end int64) (int64, error) {
+	if end < 2*id3v2HeaderLen {
+		return 0, nil
+	}
+	b := make([]byte, id3v2HeaderLen)
+	if _, err := r.ReadAt(b, end-id3v2HeaderLen); err != nil {
+		return 0, err
+	}
+	ftr, ok := parseID3v2Header(b, id3v2FooterMagic)
+	if !ok {
+		return 0, nil
+	}
+	n := ftr.totalLen()
+	if ftr.flags&id3v2FlagFooter == 0 || n > end {
+		return 0, fmt.Errorf("invalid ID3v2 footer at %#x", end-id3v2HeaderLen)
+	}
+	return n, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadTagLayoutFrom(t *testing.T) {
+	var data []byte
+	var want []TagRegion
+	add := func(typ TagType, b []byte) {
+		if typ >= 0 {
+			want = append(want, TagRegion{typ, int64(len(data)), int64(len(b))})
+		}
+		data = append(data, b...)
+	}
+	const audio TagType = -1
+
+	add(TagID3v2, makeID3v2Shell(3, 0, bytes.Repeat([]byte{'a'}, 200)))
+	add(TagID3v2Padding, make([]byte, 5000))
+	add(TagID3v2, makeID3v2Shell(4, id3v2FlagFooter, bytes.Repeat([]byte{'b'}, 300)))
+	audioStart := int64(len(data))
+	add(audio, bytes.Repeat(makeFrame(t, testHeader128, 0), 3))
+	audioEnd := int64(len(data))
+	add(TagLyrics3, makeLyrics3V2Tag("LYR", "Lyrics"))
+	add(TagAPE, makeAPETag(2000, true, []APEItem{{Key: "Artist", Value: []byte("Artist")}}))
+	add(TagID3v2, makeID3v2Shell(4, id3v2FlagFooter, bytes.Repeat([]byte{'c'}, 50)))
+	add(TagID3v1Enhanced, makeID3v1Enhanced("Title", "", "", 0, "", "", ""))
+	add(TagID3v1, makeID3v1Footer("Title", "", "", "", "", 0, 0))
+
+	layout, err := ReadTagLayoutFrom(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal("ReadTagLayoutFrom failed: ", err)
+	}
+	if !reflect.DeepEqual(layout.Regions, want) {
+		t.Errorf("ReadTagLayoutFrom returned regions %v; want %v", layout.Regions, want)
+	}
+	if layout.AudioStart != audioStart || layout.AudioEnd != audioEnd {
+		t.Errorf("ReadTagLayoutFrom returned audio [%d, %d); want [%d, %d)",
+			layout.AudioStart, layout.AudioEnd, audioStart, audioEnd)
+	}
+	if got, want := layout.FooterLen(), int64(len(data))-audioEnd; got != want {
+		t.Errorf("FooterLen() = %d; want %d", got, want)
+	}
+
+	// The computed lengths should be usable by other functions.
+	if ed, err := ComputeExactAudioDurationFrom(bytes.NewReader(data), int64(len(data)),
+		layout.HeaderLen(), layout.FooterLen()); err != nil {
+		t.Error("ComputeExactAudioDurationFrom failed: ", err)
+	} else if ed.Frames != 3 || ed.SkippedBytes != 0 {
+		t.Errorf("ComputeExactAudioDurationFrom found %d frame(s) and skipped %d byte(s); want 3 and 0",
+			ed.Frames, ed.SkippedBytes)
+	}
+
+	// A file without tags should consist entirely of audio.
+	data = bytes.Repeat(makeFrame(t, testHeader128, 0), 3)
+	if layout, err := ReadTagLayoutFrom(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Error("ReadTagLayoutFrom failed without tags: ", err)
+	} else if len(layout.Regions) != 0 || layout.AudioStart != 0 || layout.AudioEnd != int64(len(data)) {
+		t.Errorf("ReadTagLayoutFrom returned %+v without tags", layout)
+	}
+}
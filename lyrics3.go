@@ -0,0 +1,185 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Lyrics3Tag contains information from a Lyrics3 v1 or v2 block.
+// See https://id3.org/Lyrics3 and https://id3.org/Lyrics3v2.
+type Lyrics3Tag struct {
+	// Version contains the block's version: 1 or 2.
+	Version int
+	// Offset contains the offset of the start of the block (i.e. "LYRICSBEGIN")
+	// from the beginning of the file.
+	Offset int64
+	// Length contains the total length of the block in bytes.
+	Length int64
+
+	Lyrics      string // LYR field (or the entire contents of a v1 block)
+	Indications string // IND field
+	Info        string // INF field
+	Author      string // AUT field
+	Album       string // EAL field
+	Artist      string // EAR field
+	Title       string // ETT field
+	Images      string // IMG field
+
+	// Fields contains all v2 fields keyed by ID, including ones listed above.
+	Fields map[string]string
+}
+
+const (
+	lyrics3Begin     = "LYRICSBEGIN"
+	lyrics3V1End     = "LYRICSEND"
+	lyrics3V2End     = "LYRICS200"
+	lyrics3V1MaxLen  = 5100 // maximum length of v1 lyrics
+	lyrics3V2SizeLen = 6    // length of v2 size field
+)
+
+// ReadLyrics3Tag reads a Lyrics3 block from the end of f. The block may be located
+// immediately before an ID3v1 footer (as required by the spec), at the very end of
+// the file, or before an APE tag that itself precedes the ID3v1 footer.
+// If the block isn't present, the returned tag and error will be nil.
+func ReadLyrics3Tag(f *os.File, fi os.FileInfo) (*Lyrics3Tag, error) {
+	return ReadLyrics3TagFrom(f, fi.Size())
+}
+
+// ReadLyrics3TagFrom is similar to ReadLyrics3Tag but reads from the end of r,
+// which contains size bytes.
+func ReadLyrics3TagFrom(r io.ReaderAt, size int64) (*Lyrics3Tag, error) {
+	n, err := id3v1FooterLen(r, size)
+	if err != nil {
+		return nil, err
+	}
+	ends := []int64{size - n}
+	if n > 0 {
+		ends = append(ends, size)
+	}
+	if ape, err := readAPETag(r, size-n); err != nil {
+		return nil, err
+	} else if ape != nil {
+		ends = append(ends, ape.Offset)
+	}
+	for _, end := range ends {
+		if tag, err := readLyrics3Tag(r, end); err != nil || tag != nil {
+			return tag, err
+		}
+	}
+	return nil, nil
+}
+
+// readLyrics3Tag reads a Lyrics3 block ending at end in r.
+// If the block isn't present, nil is returned.
+func readLyrics3Tag(r io.ReaderAt, end int64) (*Lyrics3Tag, error) {
+	// Both versions end with 9-byte markers.
+	if end < int64(len(lyrics3Begin)+len(lyrics3V1End)) {
+		return nil, nil
+	}
+	marker := make([]byte, len(lyrics3V1End))
+	if _, err := r.ReadAt(marker, end-int64(len(marker))); err != nil {
+		return nil, err
+	}
+	switch string(marker) {
+	case lyrics3V1End:
+		return readLyrics3V1Tag(r, end-int64(len(marker)))
+	case lyrics3V2End:
+		return readLyrics3V2Tag(r, end-int64(len(marker)))
+	default:
+		return nil, nil
+	}
+}
+
+// readLyrics3V1Tag reads a Lyrics3 v1 block whose "LYRICSEND" marker starts at end.
+func readLyrics3V1Tag(r io.ReaderAt, end int64) (*Lyrics3Tag, error) {
+	// v1 blocks don't include their size, so search backward for the start marker.
+	start := end - int64(len(lyrics3Begin)+lyrics3V1MaxLen)
+	if start < 0 {
+		start = 0
+	}
+	b := make([]byte, end-start)
+	if _, err := r.ReadAt(b, start); err != nil {
+		return nil, err
+	}
+	idx := bytes.LastIndex(b, []byte(lyrics3Begin))
+	if idx < 0 {
+		return nil, errors.New("didn't find start of Lyrics3 v1 block")
+	}
+	lyrics, _ := Latin1.Bytes(b[idx+len(lyrics3Begin):])
+	return &Lyrics3Tag{
+		Version: 1,
+		Offset:  start + int64(idx),
+		Length:  end + int64(len(lyrics3V1End)) - start - int64(idx),
+		Lyrics:  string(lyrics),
+	}, nil
+}
+
+// readLyrics3V2Tag reads a Lyrics3 v2 block whose "LYRICS200" marker starts at end.
+func readLyrics3V2Tag(r io.ReaderAt, end int64) (*Lyrics3Tag, error) {
+	sb := make([]byte, lyrics3V2SizeLen)
+	if end < int64(len(sb)) {
+		return nil, errors.New("Lyrics3 v2 block lacks size")
+	}
+	if _, err := r.ReadAt(sb, end-int64(len(sb))); err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseUint(string(sb), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Lyrics3 v2 size %q", sb)
+	}
+	start := end - int64(len(sb)) - int64(size)
+	if size < uint64(len(lyrics3Begin)) || start < 0 {
+		return nil, fmt.Errorf("invalid Lyrics3 v2 size %d", size)
+	}
+
+	b := make([]byte, size)
+	if _, err := r.ReadAt(b, start); err != nil {
+		return nil, err
+	}
+	if string(b[:len(lyrics3Begin)]) != lyrics3Begin {
+		return nil, errors.New("didn't find start of Lyrics3 v2 block")
+	}
+	tag := Lyrics3Tag{
+		Version: 2,
+		Offset:  start,
+		Length:  end + int64(len(lyrics3V2End)) - start,
+		Fields:  make(map[string]string),
+	}
+
+	// Each field consists of a 3-byte ID, a 5-digit length, and the data.
+	const idLen, lenLen = 3, 5
+	for b = b[len(lyrics3Begin):]; len(b) > 0; {
+		if len(b) < idLen+lenLen {
+			return nil, errors.New("truncated Lyrics3 v2 field header")
+		}
+		id := string(b[:idLen])
+		n, err := strconv.ParseUint(string(b[idLen:idLen+lenLen]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid length for Lyrics3 v2 field %q", id)
+		}
+		b = b[idLen+lenLen:]
+		if n > uint64(len(b)) {
+			return nil, fmt.Errorf("Lyrics3 v2 field %q extends past end of block", id)
+		}
+		val, _ := Latin1.Bytes(b[:n])
+		tag.Fields[id] = string(val)
+		b = b[n:]
+	}
+
+	tag.Lyrics = tag.Fields["LYR"]
+	tag.Indications = tag.Fields["IND"]
+	tag.Info = tag.Fields["INF"]
+	tag.Author = tag.Fields["AUT"]
+	tag.Album = tag.Fields["EAL"]
+	tag.Artist = tag.Fields["EAR"]
+	tag.Title = tag.Fields["ETT"]
+	tag.Images = tag.Fields["IMG"]
+	return &tag, nil
+}
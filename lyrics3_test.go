@@ -0,0 +1,71 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// makeLyrics3V2Tag returns a Lyrics3 v2 block containing the supplied fields
+// (pairs of IDs and values).
+func makeLyrics3V2Tag(fields ...string) []byte {
+	b := []byte(lyrics3Begin)
+	for i := 0; i < len(fields); i += 2 {
+		b = append(b, fmt.Sprintf("%s%05d%s", fields[i], len(fields[i+1]), fields[i+1])...)
+	}
+	return append(b, fmt.Sprintf("%06d%s", len(b), lyrics3V2End)...)
+}
+
+func TestReadLyrics3TagFrom(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xff}, 100)
+	id3v1 := makeID3v1Footer("Title", "", "", "", "", 0, 0)
+	ape := makeAPETag(2000, true, []APEItem{{Key: "Artist", Value: []byte("Artist")}})
+	v1 := []byte(lyrics3Begin + "Some lyrics\r\nMore lyrics" + lyrics3V1End)
+	v2 := makeLyrics3V2Tag("IND", "11", "LYR", "[00:01]Caf\xe9", "EAR", "Artist", "ETT", "Title", "XYZ", "other")
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	v1Tag := Lyrics3Tag{Version: 1, Offset: 100, Length: int64(len(v1)), Lyrics: "Some lyrics\r\nMore lyrics"}
+	v2Tag := Lyrics3Tag{
+		Version:     2,
+		Offset:      100,
+		Length:      int64(len(v2)),
+		Lyrics:      "[00:01]Café",
+		Indications: "11",
+		Artist:      "Artist",
+		Title:       "Title",
+		Fields: map[string]string{
+			"IND": "11",
+			"LYR": "[00:01]Café",
+			"EAR": "Artist",
+			"ETT": "Title",
+			"XYZ": "other",
+		},
+	}
+
+	for _, tc := range []struct {
+		desc string
+		data []byte
+		want *Lyrics3Tag
+	}{
+		{"v1 before ID3v1", join(audio, v1, id3v1), &v1Tag},
+		{"v2 before ID3v1", join(audio, v2, id3v1), &v2Tag},
+		{"v2 at end", join(audio, v2), &v2Tag},
+		{"v2 before APE", join(audio, v2, ape, id3v1), &v2Tag},
+		{"none", join(audio, id3v1), nil},
+	} {
+		if tag, err := ReadLyrics3TagFrom(bytes.NewReader(tc.data), int64(len(tc.data))); err != nil {
+			t.Errorf("ReadLyrics3TagFrom failed for %v: %v", tc.desc, err)
+		} else if !reflect.DeepEqual(tag, tc.want) {
+			t.Errorf("ReadLyrics3TagFrom for %v returned %+v; want %+v", tc.desc, tag, tc.want)
+		}
+	}
+
+	bad := join(audio, []byte(lyrics3Begin+"LYR00100short"+"000024"+lyrics3V2End), id3v1)
+	if _, err := ReadLyrics3TagFrom(bytes.NewReader(bad), int64(len(bad))); err == nil {
+		t.Error("ReadLyrics3TagFrom unexpectedly succeeded for bad field length")
+	}
+}
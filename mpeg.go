@@ -5,7 +5,6 @@
 package mpeg
 
 import (
-	"bytes"
 	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
@@ -13,7 +12,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -21,67 +19,13 @@ import (
 	"github.com/derat/taglib-go/taglib/id3"
 )
 
-// ID3v1Tag contains information from an ID3v1 footer at the end of an MP3 file.
-// ID3v1 is a terrible format: https://id3.org/ID3v1
-type ID3v1Tag struct {
-	Title, Artist, Album, Year, Comment string
-	Genre, Track                        byte
-}
-
-// ID3v1Length is the length in bytes of an ID3v1 tag.
-const ID3v1Length = 128
-
-// ReadID3v1Footer reads an ID3v1 footer from the final ID3v1Length bytes of f.
-// If the tag isn't present, the returned tag and error will be nil.
-func ReadID3v1Footer(f *os.File, fi os.FileInfo) (*ID3v1Tag, error) {
-	const (
-		footerMagic = "TAG"
-		titleLen    = 30
-		artistLen   = 30
-		albumLen    = 30
-		yearLen     = 4
-		commentLen  = 30
-		genreLen    = 1
-	)
-
-	// Check for an ID3v1 footer.
-	buf := make([]byte, ID3v1Length)
-	if _, err := f.ReadAt(buf, fi.Size()-int64(len(buf))); err != nil {
-		return nil, err
-	}
-	b := bytes.NewBuffer(buf)
-	if string(b.Next(len(footerMagic))) != footerMagic {
-		return nil, nil
-	}
-
-	clean := func(b []byte) string { return string(bytes.TrimSpace(bytes.TrimRight(b, "\x00"))) }
-
-	tag := &ID3v1Tag{}
-	tag.Title = clean(b.Next(titleLen))
-	tag.Artist = clean(b.Next(artistLen))
-	tag.Album = clean(b.Next(albumLen))
-	tag.Year = clean(b.Next(yearLen))
-	comment := b.Next(commentLen)
-	tag.Genre = b.Next(genreLen)[0]
-
-	// ID3v1.1 extension: if the last byte of the comment field is non-zero but the byte before it
-	// is zero, then the last byte holds the track number.
-	idx1, idx2 := len(comment)-1, len(comment)-2
-	if comment[idx1] != 0x0 && comment[idx2] == 0x0 {
-		tag.Track = comment[idx1]
-		comment[idx1] = 0x0
-	}
-	tag.Comment = clean(comment)
-
-	return tag, nil
-}
-
 // GetID3v2TextFrame returns the first ID3v2 text frame with the supplied ID from gen.
 // If the frame isn't present, an empty string and nil error are returned.
 //
 // The taglib library has built-in support for some frames ("TPE1", "TIT2", "TALB", etc.)
 // and provides generic support for custom "TXXX" frames, but it doesn't seem to provide
-// an easy way to read other well-known frames like "TPE2".
+// an easy way to read other well-known frames like "TPE2". gen may also be an *ID3v2Tag
+// returned by ReadID3v2Tag, which additionally supports ID3v2.2 tags.
 func GetID3v2TextFrame(gen taglib.GenericTag, id string) (string, error) {
 	switch tag := gen.(type) {
 	case *id3.Id3v23Tag:
@@ -100,103 +44,462 @@ func GetID3v2TextFrame(gen taglib.GenericTag, id string) (string, error) {
 		} else {
 			return fields[0], nil
 		}
+	case *ID3v2Tag:
+		if frames := tag.Find(id); len(frames) == 0 {
+			return "", nil
+		} else if fields, err := frames[0].Text(); err != nil {
+			return "", err
+		} else {
+			return fields[0], nil
+		}
 	default:
 		return "", errors.New("unsupported ID3 version")
 	}
 }
 
+// ID3v2TextOptions configures how GetID3v2TextFrameValues splits text frames into values.
+type ID3v2TextOptions struct {
+	// SplitV23 splits values from ID3v2.2 and ID3v2.3 frames on Separators.
+	// ID3v2.4 frames use null bytes to separate multiple values, but earlier
+	// versions have no standard way to do so and taggers often use "/" instead.
+	SplitV23 bool
+	// Separators contains the strings used to split values when SplitV23 is true.
+	// If empty, "/" is used.
+	Separators []string
+}
+
+// GetID3v2TextFrameValues returns all values from all ID3v2 text frames with the supplied ID
+// in gen, in the order in which they appear. Null-separated values are always returned
+// separately. Empty values are omitted. If opts is nil, default options are used.
+// If the frame isn't present, an empty slice and nil error are returned.
+func GetID3v2TextFrameValues(gen taglib.GenericTag, id string, opts *ID3v2TextOptions) ([]string, error) {
+	if opts == nil {
+		opts = &ID3v2TextOptions{}
+	}
+	major, frames, err := getID3v2TextFrames(gen, id)
+	if err != nil {
+		return nil, err
+	}
+	seps := opts.Separators
+	if len(seps) == 0 {
+		seps = []string{"/"}
+	}
+
+	var vals []string
+	for _, fields := range frames {
+		if opts.SplitV23 && major < 4 {
+			fields = splitAll(fields, seps)
+		}
+		for _, v := range fields {
+			if v != "" {
+				vals = append(vals, v)
+			}
+		}
+	}
+	return vals, nil
+}
+
+// GetID3v2UserTextFrame returns all values from user-defined "TXXX" frames in gen
+// whose descriptions case-insensitively match desc (e.g. "MusicBrainz Album Id" or
+// "REPLAYGAIN_TRACK_GAIN"). Empty values are omitted.
+// If no matching frames are present, an empty slice and nil error are returned.
+func GetID3v2UserTextFrame(gen taglib.GenericTag, desc string) ([]string, error) {
+	_, frames, err := getID3v2TextFrames(gen, "TXXX")
+	if err != nil {
+		return nil, err
+	}
+	var vals []string
+	for _, fields := range frames {
+		if !strings.EqualFold(fields[0], desc) {
+			continue
+		}
+		for _, v := range fields[1:] {
+			if v != "" {
+				vals = append(vals, v)
+			}
+		}
+	}
+	return vals, nil
+}
+
+// GetID3v2UserTextDescriptions returns the distinct descriptions of all user-defined
+// "TXXX" frames in gen, in the order in which they appear. Descriptions are compared
+// case-insensitively (as in GetID3v2UserTextFrame), and the first spelling is returned.
+func GetID3v2UserTextDescriptions(gen taglib.GenericTag) ([]string, error) {
+	_, frames, err := getID3v2TextFrames(gen, "TXXX")
+	if err != nil {
+		return nil, err
+	}
+	var descs []string
+	seen := func(desc string) bool {
+		for _, d := range descs {
+			if strings.EqualFold(d, desc) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, fields := range frames {
+		if !seen(fields[0]) {
+			descs = append(descs, fields[0])
+		}
+	}
+	return descs, nil
+}
+
+// getID3v2TextFrames decodes all text frames with the supplied ID from gen.
+// The tag's major version (e.g. 3 for ID3v2.3) is also returned.
+func getID3v2TextFrames(gen taglib.GenericTag, id string) (major int, frames [][]string, err error) {
+	switch tag := gen.(type) {
+	case *id3.Id3v23Tag:
+		for _, fr := range tag.Frames[id] {
+			fields, err := id3.GetId3v23TextIdentificationFrame(fr)
+			if err != nil {
+				return 0, nil, err
+			}
+			frames = append(frames, fields)
+		}
+		return 3, frames, nil
+	case *id3.Id3v24Tag:
+		for _, fr := range tag.Frames[id] {
+			fields, err := id3.GetId3v24TextIdentificationFrame(fr)
+			if err != nil {
+				return 0, nil, err
+			}
+			frames = append(frames, fields)
+		}
+		return 4, frames, nil
+	case *ID3v2Tag:
+		for _, fr := range tag.Find(id) {
+			fields, err := fr.Text()
+			if err != nil {
+				return 0, nil, err
+			}
+			frames = append(frames, fields)
+		}
+		return tag.Version, frames, nil
+	default:
+		return 0, nil, errors.New("unsupported ID3 version")
+	}
+}
+
+// splitAll splits each string in vals on all of seps and trims surrounding whitespace.
+func splitAll(vals, seps []string) []string {
+	for _, sep := range seps {
+		var split []string
+		for _, v := range vals {
+			split = append(split, strings.Split(v, sep)...)
+		}
+		vals = split
+	}
+	for i, v := range vals {
+		vals[i] = strings.TrimSpace(v)
+	}
+	return vals
+}
+
 // ComputeAudioSHA1 returns a SHA1 hash of the audio (i.e. non-metadata) portion of f.
+// headerLen and footerLen contain the lengths of tags at the beginning and end of f,
+// as returned by TagLayout's HeaderLen and FooterLen methods.
 func ComputeAudioSHA1(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (string, error) {
-	if _, err := f.Seek(headerLen, 0); err != nil {
-		return "", err
-	}
+	return ComputeAudioSHA1From(f, fi.Size(), headerLen, footerLen)
+}
+
+// ComputeAudioSHA1From is similar to ComputeAudioSHA1 but reads from r, which contains size bytes.
+func ComputeAudioSHA1From(r io.ReaderAt, size, headerLen, footerLen int64) (string, error) {
 	hasher := sha1.New()
-	if _, err := io.CopyN(hasher, f, fi.Size()-headerLen-footerLen); err != nil {
+	if _, err := io.CopyN(hasher, io.NewSectionReader(r, headerLen, size-headerLen), size-headerLen-footerLen); err != nil {
 		return "", err
 	}
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-// FrameInfo contains information about an MPEG (MP3?) audio frame header.
+// FrameInfo contains information about an MPEG audio frame header.
 type FrameInfo struct {
+	Version         Version
+	Layer           Layer
 	KbitRate        int // in 1000 bits per second (not 1024)
 	SampleRate      int // in hertz
 	SamplesPerFrame int
-	ChannelMode     uint8 // 0x0 stereo, 0x1 joint stereo, 0x2 dual channel, 0x3 single channel
-	HasCRC          bool  // 16-bit CRC follows header
-	HasPadding      bool  // frame is padded with one extra bit
+	ChannelMode     ChannelMode
+	ModeExtension   ModeExtension // only meaningful for JointStereo
+	FreeFormat      bool          // bitrate isn't specified by the header; KbitRate and Size are inferred
+	HasCRC          bool          // 16-bit CRC follows header
+	HasPadding      bool          // frame is padded with one extra bit
+	Private         bool          // application-specific private bit
+	Copyright       bool          // audio is copyrighted
+	Original        bool          // audio is an original rather than a copy
+	Emphasis        Emphasis
+
+	freeSlots int64 // unpadded size in slots of a free-format frame, or 0 if unknown
+}
+
+// String returns a human-readable description of fi, e.g.
+// "MPEG-2 Layer III, joint stereo (MS+IS), emphasis none".
+func (fi *FrameInfo) String() string {
+	mode := fi.ChannelMode.String()
+	if fi.ChannelMode == JointStereo {
+		if fi.Layer == Layer3 {
+			mode += " (" + fi.ModeExtension.String() + ")"
+		} else {
+			mode += fmt.Sprintf(" (IS bands %d-31)", fi.ModeExtension.IntensityBound())
+		}
+	}
+	return fmt.Sprintf("%v %v, %v, emphasis %v", fi.Version, fi.Layer, mode, fi.Emphasis)
 }
 
+// Size returns the frame's length in bytes, including its header and padding.
+// 0 is returned for a free-format frame whose size couldn't be inferred.
 func (fi *FrameInfo) Size() int64 {
-	// See https://www.opennet.ru/docs/formats/mpeghdr.html. Calculation may be more complicated per
-	// https://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header, but if we're off we'll
-	// probably see a problem when reading the next frame.
-	s := int64(fi.SamplesPerFrame/8) * int64(fi.KbitRate*1000) / int64(fi.SampleRate)
+	if fi.SampleRate == 0 {
+		return 0
+	}
+	// See section 2.4.3.1 of ISO/IEC 11172-3 and https://www.opennet.ru/docs/formats/mpeghdr.html.
+	// Frames consist of slots, which are 4 bytes long in Layer I and 1 byte long in Layers II and III.
+	// Padding adds a single slot. Multiplying before dividing avoids truncation errors.
+	slotSize := fi.slotSize()
+	slots := fi.freeSlots
+	if !fi.FreeFormat {
+		slots = int64(fi.SamplesPerFrame) * int64(fi.KbitRate) * 1000 / (8 * slotSize * int64(fi.SampleRate))
+	} else if slots == 0 {
+		return 0
+	}
 	if fi.HasPadding {
-		s++
+		slots++
+	}
+	return slots * slotSize
+}
+
+// slotSize returns the size in bytes of the slots making up the frame.
+func (fi *FrameInfo) slotSize() int64 {
+	if fi.Layer == Layer1 {
+		return 4
+	}
+	return 1
+}
+
+// maxFreeFormatSize is the maximum size in bytes of a free-format frame.
+// A 640 kbps MPEG-2.5 Layer III frame at 8000 Hz is 5760 bytes.
+const maxFreeFormatSize = 8192
+
+// inferFreeFormatSize sets fi's size and bitrate by finding the next frame's header in b,
+// which should contain the free-format frame described by fi and header (and ideally at least
+// maxFreeFormatSize+4 bytes). False is returned if the next frame wasn't found.
+func (fi *FrameInfo) inferFreeFormatSize(header uint32, b []byte) bool {
+	// Compare the sync word, version, layer, protection bit, bitrate index, sample rate,
+	// and channel mode, which shouldn't change within a free-format stream.
+	const mask = 0xfffffcc0
+	slotSize := fi.slotSize()
+	for i := 4; i+4 <= len(b) && i <= maxFreeFormatSize; i += int(slotSize) {
+		if binary.BigEndian.Uint32(b[i:])&mask != header&mask {
+			continue
+		}
+		slots := int64(i) / slotSize
+		if fi.HasPadding {
+			slots--
+		}
+		fi.freeSlots = slots
+		fi.KbitRate = int((slots*slotSize*8*int64(fi.SampleRate)/int64(fi.SamplesPerFrame) + 500) / 1000)
+		return true
+	}
+	return false
+}
+
+// Version describes an MPEG audio version.
+type Version int
+
+const (
+	Version1   Version = iota
+	Version2           // MPEG-2 LSF (lower sampling frequencies)
+	Version2_5         // unofficial extension of MPEG2
+	versionRes         // reserved
+)
+
+// Layer describes an MPEG audio layer.
+type Layer int
+
+const (
+	Layer1   Layer = iota
+	Layer2         // typically used for .mp2 files
+	Layer3         // typically used for .mp3 files
+	layerRes       // reserved
+)
+
+func (v Version) String() string {
+	switch v {
+	case Version1:
+		return "MPEG-1"
+	case Version2:
+		return "MPEG-2"
+	case Version2_5:
+		return "MPEG-2.5"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(v))
 	}
-	return s
 }
 
-func (fi *FrameInfo) Empty() bool {
-	// TODO: This seems bogus.
-	return fi.Size() == 104
+func (l Layer) String() string {
+	switch l {
+	case Layer1:
+		return "Layer I"
+	case Layer2:
+		return "Layer II"
+	case Layer3:
+		return "Layer III"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(l))
+	}
 }
 
-type version int
+// ChannelMode describes a frame's channels.
+type ChannelMode uint8
 
 const (
-	version1 version = iota
-	version2
-	version2_5 // unofficial extension of MPEG2
-	versionRes // reserved
+	Stereo        ChannelMode = 0x0
+	JointStereo   ChannelMode = 0x1
+	DualChannel   ChannelMode = 0x2
+	SingleChannel ChannelMode = 0x3 // mono
 )
 
-type layer int
+func (m ChannelMode) String() string {
+	switch m {
+	case Stereo:
+		return "stereo"
+	case JointStereo:
+		return "joint stereo"
+	case DualChannel:
+		return "dual channel"
+	case SingleChannel:
+		return "single channel"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(m))
+	}
+}
+
+// ModeExtension contains the 2-bit mode extension from a joint stereo frame's header.
+// Its interpretation depends on the layer: in Layer III it describes which joint stereo
+// coding methods are used, while in Layers I and II it describes which subbands use
+// intensity stereo.
+type ModeExtension uint8
+
+// IntensityStereo returns true if intensity stereo is used in a Layer III frame.
+func (m ModeExtension) IntensityStereo() bool { return m&0x1 != 0 }
+
+// MSStereo returns true if M/S stereo is used in a Layer III frame.
+func (m ModeExtension) MSStereo() bool { return m&0x2 != 0 }
+
+// IntensityBound returns the first subband that uses intensity stereo in a Layer I or II frame.
+// Subbands from the returned value through 31 use intensity stereo.
+func (m ModeExtension) IntensityBound() int { return 4 + 4*int(m&0x3) }
+
+// String describes m's Layer III interpretation, e.g. "MS+IS" or "none".
+func (m ModeExtension) String() string {
+	switch {
+	case m.MSStereo() && m.IntensityStereo():
+		return "MS+IS"
+	case m.MSStereo():
+		return "MS"
+	case m.IntensityStereo():
+		return "IS"
+	default:
+		return "none"
+	}
+}
+
+// Emphasis describes the de-emphasis that should be applied to a frame's audio.
+type Emphasis uint8
 
 const (
-	layer1 layer = iota
-	layer2
-	layer3
-	layerRes // reserved
+	EmphasisNone  Emphasis = 0x0
+	Emphasis50_15 Emphasis = 0x1 // 50/15 ms
+	emphasisRes   Emphasis = 0x2 // reserved
+	EmphasisCCITT Emphasis = 0x3 // CCITT J.17
 )
 
-var versions = [...]version{version2_5, versionRes, version2, version1}
-var layers = [...]layer{layerRes, layer3, layer2, layer1}
+func (e Emphasis) String() string {
+	switch e {
+	case EmphasisNone:
+		return "none"
+	case Emphasis50_15:
+		return "50/15 ms"
+	case emphasisRes:
+		return "reserved"
+	case EmphasisCCITT:
+		return "CCITT J.17"
+	default:
+		return fmt.Sprintf("invalid (%d)", int(e))
+	}
+}
 
-// Specific to Layer III.
-var samplesPerFrame = map[version]int{
-	version1:   1152,
-	version2:   576,
-	version2_5: 576,
+var versions = [...]Version{Version2_5, versionRes, Version2, Version1}
+var layers = [...]Layer{layerRes, Layer3, Layer2, Layer1}
+
+var samplesPerFrame = map[Version]map[Layer]int{
+	Version1:   {Layer1: 384, Layer2: 1152, Layer3: 1152},
+	Version2:   {Layer1: 384, Layer2: 1152, Layer3: 576},
+	Version2_5: {Layer1: 384, Layer2: 1152, Layer3: 576},
 }
 
-// Specific to Layer III. Values are multiples of 1000 bits.
-var kbitRates = map[version][]int{
-	version1:   {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
-	version2:   {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
-	version2_5: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}, // same as version2
+// Values are multiples of 1000 bits.
+var kbitRates = map[Version]map[Layer][]int{
+	Version1: {
+		Layer1: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		Layer2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		Layer3: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	Version2: {
+		Layer1: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		Layer2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		Layer3: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}, // same as Layer2
+	},
+	Version2_5: { // same as Version2
+		Layer1: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		Layer2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		Layer3: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
 }
 
 // Values are in Hertz.
-var sampleRates = map[version][]int{
-	version1:   {44100, 48000, 32000, 0},
-	version2:   {22050, 24000, 16000, 0},
-	version2_5: {11025, 12000, 8000, 0},
+var sampleRates = map[Version][]int{
+	Version1:   {44100, 48000, 32000, 0},
+	Version2:   {22050, 24000, 16000, 0},
+	Version2_5: {11025, 12000, 8000, 0},
 }
 
-var unsupportedLayerErr = errors.New("unsupported layer")
-
 // ReadFrameInfo reads an MPEG audio frame header at the specified offset in f.
 // Format details at http://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header.
 func ReadFrameInfo(f *os.File, start int64) (*FrameInfo, error) {
-	if _, err := f.Seek(start, 0); err != nil {
+	return ReadFrameInfoFrom(f, start)
+}
+
+// ReadFrameInfoFrom is similar to ReadFrameInfo but reads from r.
+// If the frame uses a free-format bitrate, its size and bitrate are inferred from the position
+// of the next frame's header.
+func ReadFrameInfoFrom(r io.ReaderAt, start int64) (*FrameInfo, error) {
+	b := make([]byte, 4)
+	if _, err := r.ReadAt(b, start); err != nil {
 		return nil, err
 	}
-	var header uint32
-	if err := binary.Read(f, binary.BigEndian, &header); err != nil {
+	header := binary.BigEndian.Uint32(b)
+	fi, err := parseFrameHeader(header)
+	if err != nil || !fi.FreeFormat {
+		return fi, err
+	}
+
+	b = make([]byte, maxFreeFormatSize+4)
+	n, err := r.ReadAt(b, start)
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
+	if !fi.inferFreeFormatSize(header, b[:n]) {
+		return nil, errors.New("didn't find next free-format frame")
+	}
+	return fi, nil
+}
+
+// parseFrameHeader parses the supplied 4-byte MPEG audio frame header.
+// The size and bitrate of free-format frames are left unset.
+func parseFrameHeader(header uint32) (*FrameInfo, error) {
 	getBits := func(startBit, numBits uint) uint32 {
 		return (header << startBit) >> (32 - numBits)
 	}
@@ -207,19 +510,28 @@ func ReadFrameInfo(f *os.File, start int64) (*FrameInfo, error) {
 	if version == versionRes {
 		return nil, errors.New("invalid MPEG version")
 	}
-	if layer := layers[getBits(13, 2)]; layer != layer3 {
-		return nil, unsupportedLayerErr
+	layer := layers[getBits(13, 2)]
+	if layer == layerRes {
+		return nil, errors.New("invalid layer")
 	}
 
 	finfo := FrameInfo{
-		KbitRate:        kbitRates[version][getBits(16, 4)],
+		Version:         version,
+		Layer:           layer,
+		KbitRate:        kbitRates[version][layer][getBits(16, 4)],
+		FreeFormat:      getBits(16, 4) == 0x0,
 		SampleRate:      sampleRates[version][getBits(20, 2)],
-		SamplesPerFrame: samplesPerFrame[version],
-		ChannelMode:     uint8(getBits(24, 2)),
+		SamplesPerFrame: samplesPerFrame[version][layer],
+		ChannelMode:     ChannelMode(getBits(24, 2)),
+		ModeExtension:   ModeExtension(getBits(26, 2)),
 		HasCRC:          getBits(15, 1) == 0x0,
 		HasPadding:      getBits(22, 1) == 0x1,
+		Private:         getBits(23, 1) == 0x1,
+		Copyright:       getBits(28, 1) == 0x1,
+		Original:        getBits(29, 1) == 0x1,
+		Emphasis:        Emphasis(getBits(30, 2)),
 	}
-	if finfo.KbitRate == 0 {
+	if finfo.KbitRate == 0 && !finfo.FreeFormat {
 		return nil, errors.New("invalid bitrate")
 	} else if finfo.SampleRate == 0 {
 		return nil, errors.New("invalid sampling rate")
@@ -232,175 +544,50 @@ func ReadFrameInfo(f *os.File, start int64) (*FrameInfo, error) {
 // many bytes to try to find something that looks like a proper header.
 const maxFrameSearchBytes = 8192
 
-// ComputeAudioDuration reads an Xing header from the frame at headerLen in f to return the audio length.
-// If no Xing header is present, it assumes that the file has a constant bitrate and returns a nil
-// VBRInfo struct. Only supports MPEG Audio 1, Layer 3.
-// TODO: Consider adding support for VBRI headers, apparently only writte by the Fraunhofer
-// encoder: https://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header#VBRIHeader
+// ComputeAudioDuration reads an Xing or VBRI header from the frame at headerLen in f to return the
+// audio length. If no VBR header is present (as is always the case for Layer I and II files), it
+// assumes that the file has a constant bitrate and returns a nil VBRInfo struct.
+// As with ComputeAudioSHA1, headerLen and footerLen can be obtained via ReadTagLayout.
 func ComputeAudioDuration(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (time.Duration, *VBRInfo, error) {
-	// Scan forward in case there's empty space or other junk before the first frame.
-	var finfo *FrameInfo
-	var err error
-	fstart := headerLen
-	for ; fstart < headerLen+maxFrameSearchBytes; fstart++ {
-		if finfo, err = ReadFrameInfo(f, fstart); err == nil {
-			break
-		} else if err == unsupportedLayerErr {
-			return 0, nil, err
-		}
-	}
-	if err != nil {
-		return 0, nil, fmt.Errorf("didn't find header after %#x", headerLen)
-	}
-
-	// Figure out where the Xing header should start.
-	xingStart := fstart + 4
-	if finfo.ChannelMode == 0x3 { // mono
-		xingStart += 17
-	} else {
-		xingStart += 32
-	}
-	if finfo.HasCRC {
-		xingStart += 2
-	}
-	if _, err := f.Seek(xingStart, io.SeekStart); err != nil {
-		return 0, nil, fmt.Errorf("seek to Xing header at %#x: %v", xingStart, err)
-	}
-
-	// Read 4-byte ID at beginning of header.
-	id := make([]byte, 4)
-	if _, err := f.Read(id); err != nil {
-		return 0, nil, err
-	}
-	if VBRHeaderID(id) != XingID && VBRHeaderID(id) != InfoID {
-		// Okay, no Xing VBR header. Assume that the file has a fixed bitrate.
-		// (The other alternative is to read the whole file to count the number of frames.)
-		ms := (fi.Size() - fstart - footerLen) / int64(finfo.KbitRate) * 8
-		return time.Duration(ms) * time.Millisecond, nil, nil
-	}
-	vbrInfo := VBRInfo{ID: VBRHeaderID(id)}
+	return ComputeAudioDurationFrom(f, fi.Size(), headerLen, footerLen)
+}
 
-	// Read 4-byte flags indicating which fields are present.
-	var flags uint32
-	if err := binary.Read(f, binary.BigEndian, &flags); err != nil {
+// ComputeAudioDurationFrom is similar to ComputeAudioDuration but reads from r, which contains size bytes.
+func ComputeAudioDurationFrom(r io.ReaderAt, size, headerLen, footerLen int64) (time.Duration, *VBRInfo, error) {
+	fstart, finfo, err := findFirstFrame(r, headerLen)
+	if err != nil {
 		return 0, nil, err
 	}
-
-	// Read 4-byte frame count. This is optional in the spec, but we require it since it's
-	// needed to compute the duration.
-	if flags&0x1 == 0 {
-		return 0, nil, errors.New("Xing header lacks number of frames")
-	}
-	if err := binary.Read(f, binary.BigEndian, &vbrInfo.Frames); err != nil {
+	vbrInfo, err := readVBRInfo(r, size, fstart, finfo)
+	if err != nil {
 		return 0, nil, err
 	}
-
-	// Read 4-byte byte count if present.
-	if flags&0x2 != 0 {
-		if err := binary.Read(f, binary.BigEndian, &vbrInfo.Bytes); err != nil {
-			return 0, nil, err
-		}
-	}
-
-	// Skip 100-byte TOC if present.
-	if flags&0x3 != 0 {
-		if _, err := f.Seek(100, io.SeekCurrent); err != nil {
-			return 0, nil, err
-		}
-	}
-
-	// Read 4-byte quality indicator if present.
-	if flags&0x4 != 0 {
-		var quality uint32
-		if err := binary.Read(f, binary.BigEndian, &quality); err != nil {
-			return 0, nil, err
-		}
-		vbrInfo.Quality = int(quality)
-	}
-
-	// Try to read the beginning of the LAME extension:
-	// http://gabriel.mp3-tech.org/mp3infotag.html
-	b := make([]byte, 10)
-	if _, err := f.Read(b); err == nil {
-		enc := b[:9]
-		ver := (b[9] & 0xf0) >> 4
-		if (ver == 0 || ver == 1) && isEncoderString(enc) {
-			vbrInfo.Encoder = strings.TrimSpace(string(enc))
-			vbrInfo.Method = EncodingMethod(b[9] & 0xf)
+	if vbrInfo == nil {
+		if finfo.KbitRate == 0 {
+			return 0, nil, errors.New("unknown bitrate")
 		}
+		// Okay, no VBR header. Assume that the file has a fixed bitrate.
+		// ComputeExactAudioDuration can be used to instead count the number of frames.
+		ms := (size - fstart - footerLen) / int64(finfo.KbitRate) * 8
+		return time.Duration(ms) * time.Millisecond, nil, nil
 	}
-
 	ms := int64(finfo.SamplesPerFrame) * int64(vbrInfo.Frames) * 1000 / int64(finfo.SampleRate)
-	return time.Duration(ms) * time.Millisecond, &vbrInfo, nil
+	return time.Duration(ms) * time.Millisecond, vbrInfo, nil
 }
 
-// isEncoderString returns true if b contains only printable characters.
-func isEncoderString(b []byte) bool {
-	for _, ch := range b {
-		if !strconv.IsPrint(rune(ch)) {
-			return false
+// findFirstFrame returns the offset and header of the first frame at or after headerLen in r.
+func findFirstFrame(r io.ReaderAt, headerLen int64) (int64, *FrameInfo, error) {
+	// Scan forward in case there's empty space or other junk before the first frame.
+	var finfo *FrameInfo
+	var err error
+	fstart := headerLen
+	for ; fstart < headerLen+maxFrameSearchBytes; fstart++ {
+		if finfo, err = ReadFrameInfoFrom(r, fstart); err == nil {
+			break
 		}
 	}
-	return true
-}
-
-// VBRInfo contains information from an Xing (or Info) header in the first frame.
-// See https://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header#XINGHeader.
-type VBRInfo struct {
-	// ID contains the ID from the beginning of the header.
-	ID VBRHeaderID
-	// Frames contains the number of audio frames in the file.
-	Frames uint32
-	// Bytes contains the number of bytes of audio data in the file.
-	Bytes uint32
-	// Quality contains a poorly-defined quality indicator in the range [0, 100].
-	Quality int
-	// Encoder describes the encoder version, e.g. "LAME3.90a".
-	Encoder string
-	// Method describes how the audio was encoded.
-	Method EncodingMethod
-}
-
-// VBRHeaderID describes the type of header used to fill a VBRInfo.
-type VBRHeaderID string
-
-const (
-	// XingID typically indicates a VBR or ABR stream.
-	XingID VBRHeaderID = "Xing"
-	// InfoID typically indicates a CBR stream.
-	InfoID VBRHeaderID = "Info"
-)
-
-// EncodingMethod describes the encoding method used for the file.
-type EncodingMethod int
-
-const (
-	UnknownMethod EncodingMethod = 0
-	CBR           EncodingMethod = 1
-	ABR           EncodingMethod = 2
-	VBR1          EncodingMethod = 3 // Lame: VBR old / VBR RH
-	VBR2          EncodingMethod = 4 // Lame: VBR MTRH
-	VBR3          EncodingMethod = 5 // LAME: VBR MT
-	VBR4          EncodingMethod = 6
-	CBR2Pass      EncodingMethod = 8
-	ABR2Pass      EncodingMethod = 9
-)
-
-var encMethodNames = map[EncodingMethod]string{
-	UnknownMethod: "unknown",
-	CBR:           "CBR",
-	ABR:           "ABR",
-	VBR1:          "VBR1",
-	VBR2:          "VBR2",
-	VBR3:          "VBR3",
-	VBR4:          "VBR4",
-	CBR2Pass:      "CBR 2-pass",
-	ABR2Pass:      "ABR 2-pass",
-}
-
-func (m EncodingMethod) String() string {
-	if s, ok := encMethodNames[m]; ok {
-		return s
+	if err != nil {
+		return 0, nil, fmt.Errorf("didn't find header after %#x", headerLen)
 	}
-	return fmt.Sprintf("invalid (%d)", int(m))
+	return fstart, finfo, nil
 }
@@ -0,0 +1,340 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/derat/taglib-go/taglib"
+)
+
+func TestComputeAudioSHA1From(t *testing.T) {
+	header := []byte("header")
+	audio := []byte("some audio data")
+	footer := []byte("footer")
+	data := append(append(append([]byte{}, header...), audio...), footer...)
+
+	sum := sha1.Sum(audio)
+	want := hex.EncodeToString(sum[:])
+	if got, err := ComputeAudioSHA1From(bytes.NewReader(data), int64(len(data)),
+		int64(len(header)), int64(len(footer))); err != nil {
+		t.Error("ComputeAudioSHA1From failed: ", err)
+	} else if got != want {
+		t.Errorf("ComputeAudioSHA1From returned %v; want %v", got, want)
+	}
+}
+
+func TestComputeAudioDuration_Layers(t *testing.T) {
+	for _, tc := range []struct {
+		header uint32
+		ver    Version
+		layer  Layer
+		size   int64
+		dur    time.Duration
+	}{
+		{0xfffb9000, Version1, Layer3, 417, 256 * time.Millisecond},   // 128 kbps, 44100 Hz
+		{0xfffda400, Version1, Layer2, 576, 240 * time.Millisecond},   // 192 kbps, 48000 Hz
+		{0xffffc000, Version1, Layer1, 416, 80 * time.Millisecond},    // 384 kbps, 44100 Hz
+		{0xfff3a400, Version2, Layer3, 288, 240 * time.Millisecond},   // 96 kbps, 24000 Hz
+		{0xfff5a400, Version2, Layer2, 576, 480 * time.Millisecond},   // 96 kbps, 24000 Hz
+		{0xffe3a400, Version2_5, Layer3, 576, 480 * time.Millisecond}, // 96 kbps, 12000 Hz
+	} {
+		var data []byte
+		for i := 0; i < 10; i++ {
+			data = append(data, makeFrame(t, tc.header, 0)...)
+		}
+		fi, err := ReadFrameInfoFrom(bytes.NewReader(data), 0)
+		if err != nil {
+			t.Errorf("ReadFrameInfoFrom for %#x failed: %v", tc.header, err)
+			continue
+		}
+		if fi.Version != tc.ver || fi.Layer != tc.layer || fi.Size() != tc.size {
+			t.Errorf("ReadFrameInfoFrom for %#x returned version %v, layer %v, size %v; want %v, %v, %v",
+				tc.header, fi.Version, fi.Layer, fi.Size(), tc.ver, tc.layer, tc.size)
+		}
+		if dur, _, err := ComputeAudioDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0); err != nil {
+			t.Errorf("ComputeAudioDurationFrom for %#x failed: %v", tc.header, err)
+		} else if dur != tc.dur {
+			t.Errorf("ComputeAudioDurationFrom for %#x returned %v; want %v", tc.header, dur, tc.dur)
+		}
+	}
+}
+
+func TestParseFrameHeader_Fields(t *testing.T) {
+	for _, tc := range []struct {
+		header uint32
+		desc   string
+		flags  [3]bool // private, copyright, original
+	}{
+		{0xfff3957c, "MPEG-2 Layer III, joint stereo (MS+IS), emphasis none", [3]bool{true, true, true}},
+		{0xfffda460, "MPEG-1 Layer II, joint stereo (IS bands 12-31), emphasis none", [3]bool{}},
+		{0xfffb90c1, "MPEG-1 Layer III, single channel, emphasis 50/15 ms", [3]bool{}},
+		{0xfffb9007, "MPEG-1 Layer III, stereo, emphasis CCITT J.17", [3]bool{false, false, true}},
+	} {
+		fi, err := parseFrameHeader(tc.header)
+		if err != nil {
+			t.Errorf("parseFrameHeader(%#x) failed: %v", tc.header, err)
+			continue
+		}
+		if got := fi.String(); got != tc.desc {
+			t.Errorf("parseFrameHeader(%#x) returned %q; want %q", tc.header, got, tc.desc)
+		}
+		if got := [3]bool{fi.Private, fi.Copyright, fi.Original}; got != tc.flags {
+			t.Errorf("parseFrameHeader(%#x) returned private/copyright/original %v; want %v",
+				tc.header, got, tc.flags)
+		}
+	}
+}
+
+func TestFrameInfo_Size(t *testing.T) {
+	// Check at least one bitrate for each version, layer, and sample rate.
+	for _, tc := range []struct {
+		header       uint32 // unpadded
+		size, padded int64
+	}{
+		{0xffff1000, 32, 36},     // MPEG-1 Layer I, 32 kbps, 44100 Hz
+		{0xffffe000, 484, 488},   // MPEG-1 Layer I, 448 kbps, 44100 Hz
+		{0xffff9400, 288, 292},   // MPEG-1 Layer I, 288 kbps, 48000 Hz
+		{0xffff1800, 48, 52},     // MPEG-1 Layer I, 32 kbps, 32000 Hz
+		{0xffff5800, 240, 244},   // MPEG-1 Layer I, 160 kbps, 32000 Hz
+		{0xffffe800, 672, 676},   // MPEG-1 Layer I, 448 kbps, 32000 Hz
+		{0xfffd1000, 104, 105},   // MPEG-1 Layer II, 32 kbps, 44100 Hz
+		{0xfffde000, 1253, 1254}, // MPEG-1 Layer II, 384 kbps, 44100 Hz
+		{0xfffd9400, 480, 481},   // MPEG-1 Layer II, 160 kbps, 48000 Hz
+		{0xfffd5800, 360, 361},   // MPEG-1 Layer II, 80 kbps, 32000 Hz
+		{0xfffde800, 1728, 1729}, // MPEG-1 Layer II, 384 kbps, 32000 Hz
+		{0xfffb1000, 104, 105},   // MPEG-1 Layer III, 32 kbps, 44100 Hz
+		{0xfffb9000, 417, 418},   // MPEG-1 Layer III, 128 kbps, 44100 Hz
+		{0xfffbe000, 1044, 1045}, // MPEG-1 Layer III, 320 kbps, 44100 Hz
+		{0xfffb9400, 384, 385},   // MPEG-1 Layer III, 128 kbps, 48000 Hz
+		{0xfffbe400, 960, 961},   // MPEG-1 Layer III, 320 kbps, 48000 Hz
+		{0xfffb5800, 288, 289},   // MPEG-1 Layer III, 64 kbps, 32000 Hz
+		{0xfffbe800, 1440, 1441}, // MPEG-1 Layer III, 320 kbps, 32000 Hz
+		{0xfff71000, 68, 72},     // MPEG-2 Layer I, 32 kbps, 22050 Hz
+		{0xfff7e000, 556, 560},   // MPEG-2 Layer I, 256 kbps, 22050 Hz
+		{0xfff79400, 288, 292},   // MPEG-2 Layer I, 144 kbps, 24000 Hz
+		{0xfff75800, 240, 244},   // MPEG-2 Layer I, 80 kbps, 16000 Hz
+		{0xfff7e800, 768, 772},   // MPEG-2 Layer I, 256 kbps, 16000 Hz
+		{0xfff51000, 52, 53},     // MPEG-2 Layer II, 8 kbps, 22050 Hz
+		{0xfff5e000, 1044, 1045}, // MPEG-2 Layer II, 160 kbps, 22050 Hz
+		{0xfff59400, 480, 481},   // MPEG-2 Layer II, 80 kbps, 24000 Hz
+		{0xfff55800, 360, 361},   // MPEG-2 Layer II, 40 kbps, 16000 Hz
+		{0xfff5e800, 1440, 1441}, // MPEG-2 Layer II, 160 kbps, 16000 Hz
+		{0xfff31000, 26, 27},     // MPEG-2 Layer III, 8 kbps, 22050 Hz
+		{0xfff3e000, 522, 523},   // MPEG-2 Layer III, 160 kbps, 22050 Hz
+		{0xfff39400, 240, 241},   // MPEG-2 Layer III, 80 kbps, 24000 Hz
+		{0xfff35800, 180, 181},   // MPEG-2 Layer III, 40 kbps, 16000 Hz
+		{0xfff3e800, 720, 721},   // MPEG-2 Layer III, 160 kbps, 16000 Hz
+		{0xffe71000, 136, 140},   // MPEG-2.5 Layer I, 32 kbps, 11025 Hz
+		{0xffe7e000, 1112, 1116}, // MPEG-2.5 Layer I, 256 kbps, 11025 Hz
+		{0xffe79400, 576, 580},   // MPEG-2.5 Layer I, 144 kbps, 12000 Hz
+		{0xffe75800, 480, 484},   // MPEG-2.5 Layer I, 80 kbps, 8000 Hz
+		{0xffe7e800, 1536, 1540}, // MPEG-2.5 Layer I, 256 kbps, 8000 Hz
+		{0xffe51000, 104, 105},   // MPEG-2.5 Layer II, 8 kbps, 11025 Hz
+		{0xffe5e000, 2089, 2090}, // MPEG-2.5 Layer II, 160 kbps, 11025 Hz
+		{0xffe59400, 960, 961},   // MPEG-2.5 Layer II, 80 kbps, 12000 Hz
+		{0xffe55800, 720, 721},   // MPEG-2.5 Layer II, 40 kbps, 8000 Hz
+		{0xffe5e800, 2880, 2881}, // MPEG-2.5 Layer II, 160 kbps, 8000 Hz
+		{0xffe31000, 52, 53},     // MPEG-2.5 Layer III, 8 kbps, 11025 Hz
+		{0xffe3e000, 1044, 1045}, // MPEG-2.5 Layer III, 160 kbps, 11025 Hz
+		{0xffe39400, 480, 481},   // MPEG-2.5 Layer III, 80 kbps, 12000 Hz
+		{0xffe31800, 72, 73},     // MPEG-2.5 Layer III, 8 kbps, 8000 Hz
+		{0xffe35800, 360, 361},   // MPEG-2.5 Layer III, 40 kbps, 8000 Hz
+		{0xffe3e800, 1440, 1441}, // MPEG-2.5 Layer III, 160 kbps, 8000 Hz
+	} {
+		for _, pad := range []bool{false, true} {
+			header, want := tc.header, tc.size
+			if pad {
+				header, want = header|0x200, tc.padded
+			}
+			if fi, err := parseFrameHeader(header); err != nil {
+				t.Errorf("parseFrameHeader(%#x) failed: %v", header, err)
+			} else if got := fi.Size(); got != want {
+				t.Errorf("Size() for %#x (%v) = %d; want %d", header, fi, got, want)
+			} else if std := standardFrameSize(fi.Version, fi.Layer, fi.KbitRate, fi.SampleRate, pad); std != want {
+				t.Errorf("standardFrameSize() for %#x (%v) = %d; want %d", header, fi, std, want)
+			}
+		}
+	}
+
+	// Check every combination of version, layer, bitrate, sample rate, and padding.
+	versions := map[uint32]Version{0x0: Version2_5, 0x2: Version2, 0x3: Version1}
+	layers := map[uint32]Layer{0x1: Layer3, 0x2: Layer2, 0x3: Layer1}
+	for vbits, version := range versions {
+		for lbits, layer := range layers {
+			for br := uint32(0x1); br <= 0xe; br++ {
+				for sr := uint32(0x0); sr <= 0x2; sr++ {
+					for _, pad := range []bool{false, true} {
+						header := 0xffe10000 | vbits<<19 | lbits<<17 | br<<12 | sr<<10
+						if pad {
+							header |= 0x200
+						}
+						fi, err := parseFrameHeader(header)
+						if err != nil {
+							t.Errorf("parseFrameHeader(%#x) failed: %v", header, err)
+							continue
+						}
+						kbps := kbitRates[version][layer][br]
+						rate := sampleRates[version][sr]
+						if got, want := fi.Size(), standardFrameSize(version, layer, kbps, rate, pad); got != want {
+							t.Errorf("Size() for %#x (%v, %d kbps, %d Hz, padding %v) = %d; want %d",
+								header, fi, kbps, rate, pad, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// standardFrameSize returns the size in bytes of a frame with the supplied parameters
+// using the commonly-cited per-layer formulas.
+func standardFrameSize(version Version, layer Layer, kbps, rate int, pad bool) int64 {
+	var p int64
+	if pad {
+		p = 1
+	}
+	br := int64(kbps) * 1000
+	sr := int64(rate)
+	switch {
+	case layer == Layer1:
+		return (12*br/sr + p) * 4 // 4-byte slots
+	case layer == Layer3 && version != Version1:
+		return 72*br/sr + p
+	default:
+		return 144*br/sr + p
+	}
+}
+
+func TestGetID3v2TextFrameValues(t *testing.T) {
+	// makeTag returns a tag with the supplied major version containing two TPE1 frames.
+	makeTag := func(major byte, first, second string) []byte {
+		id := "TPE1"
+		if major == 2 {
+			id = "TP1"
+		}
+		var body []byte
+		for _, s := range []string{first, second} {
+			body = append(body, makeID3v2Frame(major, id, 0, []byte("\x00"+s), true)...)
+		}
+		body = append(body, make([]byte, 16)...) // padding
+		return makeID3v2Shell(major, 0, body)
+	}
+
+	for _, tc := range []struct {
+		major  byte
+		native bool // parse using ReadID3v2TagFrom instead of taglib
+		first  string
+		second string
+		opts   *ID3v2TextOptions
+		want   []string
+	}{
+		{2, true, "A/B", "C", nil, []string{"A/B", "C"}},
+		{2, true, "A / B", "C", &ID3v2TextOptions{SplitV23: true}, []string{"A", "B", "C"}},
+		{3, false, "A/B", "C", nil, []string{"A/B", "C"}},
+		{3, false, "A/B", "C", &ID3v2TextOptions{SplitV23: true}, []string{"A", "B", "C"}},
+		{3, false, "A; B/C", "", &ID3v2TextOptions{SplitV23: true, Separators: []string{";"}},
+			[]string{"A", "B/C"}},
+		{3, true, "A/B", "C", &ID3v2TextOptions{SplitV23: true}, []string{"A", "B", "C"}},
+		{4, false, "A\x00B", "C/D", nil, []string{"A", "B", "C/D"}},
+		{4, false, "A\x00B", "C/D", &ID3v2TextOptions{SplitV23: true}, []string{"A", "B", "C/D"}},
+		{4, true, "A\x00B\x00", "C", nil, []string{"A", "B", "C"}},
+	} {
+		data := makeTag(tc.major, tc.first, tc.second)
+		var tag taglib.GenericTag
+		var err error
+		if tc.native {
+			tag, err = ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 0)
+		} else {
+			tag, err = taglib.Decode(bytes.NewReader(data), int64(len(data)))
+		}
+		if err != nil {
+			t.Errorf("Reading v2.%d tag failed: %v", tc.major, err)
+			continue
+		}
+		if got, err := GetID3v2TextFrameValues(tag, "TPE1", tc.opts); err != nil {
+			t.Errorf("GetID3v2TextFrameValues(v2.%d, %q, %+v) failed: %v", tc.major, "TPE1", tc.opts, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("GetID3v2TextFrameValues(v2.%d, %q, %+v) = %q; want %q",
+				tc.major, "TPE1", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestGetID3v2UserTextFrame(t *testing.T) {
+	for _, tc := range []struct {
+		major  byte
+		native bool // parse using ReadID3v2TagFrom instead of taglib
+	}{
+		{3, false},
+		{4, false},
+		{3, true},
+		{4, true},
+	} {
+		var body []byte
+		for _, s := range []string{
+			"MusicBrainz Album Id\x00abc",
+			"REPLAYGAIN_TRACK_GAIN\x00-6.5 dB",
+			"musicbrainz album id\x00def",
+			"Empty\x00",
+		} {
+			body = append(body, makeID3v2Frame(tc.major, "TXXX", 0, []byte("\x00"+s), true)...)
+		}
+		if tc.major == 4 {
+			body = append(body, makeID3v2Frame(4, "TXXX", 0, []byte("\x03CATALOG\x00X1\x00X2"), true)...)
+		}
+		data := makeID3v2Shell(tc.major, 0, body)
+
+		var tag taglib.GenericTag
+		var err error
+		if tc.native {
+			tag, err = ReadID3v2TagFrom(bytes.NewReader(data), int64(len(data)), 0)
+		} else {
+			tag, err = taglib.Decode(bytes.NewReader(data), int64(len(data)))
+		}
+		if err != nil {
+			t.Errorf("Reading v2.%d tag failed: %v", tc.major, err)
+			continue
+		}
+
+		for _, vc := range []struct {
+			desc string
+			want []string
+		}{
+			{"MUSICBRAINZ ALBUM ID", []string{"abc", "def"}},
+			{"replaygain_track_gain", []string{"-6.5 dB"}},
+			{"Empty", nil},
+			{"Missing", nil},
+		} {
+			if got, err := GetID3v2UserTextFrame(tag, vc.desc); err != nil {
+				t.Errorf("GetID3v2UserTextFrame(v2.%d, %q) failed: %v", tc.major, vc.desc, err)
+			} else if !reflect.DeepEqual(got, vc.want) {
+				t.Errorf("GetID3v2UserTextFrame(v2.%d, %q) = %q; want %q", tc.major, vc.desc, got, vc.want)
+			}
+		}
+		if tc.major == 4 {
+			want := []string{"X1", "X2"}
+			if got, err := GetID3v2UserTextFrame(tag, "catalog"); err != nil {
+				t.Errorf("GetID3v2UserTextFrame(v2.4, %q) failed: %v", "catalog", err)
+			} else if !reflect.DeepEqual(got, want) {
+				t.Errorf("GetID3v2UserTextFrame(v2.4, %q) = %q; want %q", "catalog", got, want)
+			}
+		}
+
+		want := []string{"MusicBrainz Album Id", "REPLAYGAIN_TRACK_GAIN", "Empty"}
+		if tc.major == 4 {
+			want = append(want, "CATALOG")
+		}
+		if got, err := GetID3v2UserTextDescriptions(tag); err != nil {
+			t.Errorf("GetID3v2UserTextDescriptions(v2.%d) failed: %v", tc.major, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetID3v2UserTextDescriptions(v2.%d) = %q; want %q", tc.major, got, want)
+		}
+	}
+}
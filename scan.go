@@ -0,0 +1,212 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Frame describes an MPEG audio frame found by FrameScanner.
+type Frame struct {
+	// Offset contains the offset of the frame's header from the beginning of the file.
+	Offset int64
+	// Size contains the frame's length in bytes, including its header.
+	Size int64
+	// Info contains information from the frame's header.
+	Info *FrameInfo
+	// Skipped contains the number of unparseable bytes that were skipped
+	// between the end of the previous frame (or the start of the audio data)
+	// and this frame.
+	Skipped int64
+}
+
+// FrameScanner iterates over the MPEG audio frames in a file.
+//
+// Its usage is similar to bufio.Scanner:
+//
+//	s := NewFrameScanner(r, size, headerLen, footerLen)
+//	for s.Next() {
+//		frame := s.Frame()
+//		...
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// If junk is encountered between frames, FrameScanner scans forward until it
+// finds a frame that is followed by another frame with a compatible header.
+type FrameScanner struct {
+	r          io.ReaderAt
+	start, end int64 // audio data is in [start, end)
+
+	off     int64 // offset of next expected frame
+	synced  bool  // true if the frame at off is expected to be valid
+	frame   Frame // most-recently-found frame
+	err     error // I/O error
+	skipped int64 // total number of skipped bytes
+
+	trailing  int64  // skipped bytes after the final frame
+	truncated *Frame // final frame if it extends past end
+
+	buf    []byte // buffered data
+	bufOff int64  // offset of buf in r
+}
+
+// scanBufSize is the number of bytes that FrameScanner reads at a time.
+const scanBufSize = 64 * 1024
+
+// NewFrameScanner returns a FrameScanner that iterates over the frames in r,
+// which contains size bytes. The audio data is assumed to start headerLen bytes
+// from the beginning of r and end footerLen bytes before the end of r. These are
+// typically the values returned by TagLayout's HeaderLen and FooterLen methods.
+func NewFrameScanner(r io.ReaderAt, size, headerLen, footerLen int64) *FrameScanner {
+	return &FrameScanner{r: r, start: headerLen, end: size - footerLen, off: headerLen}
+}
+
+// Next advances to the next frame, which will be available via Frame.
+// False is returned when no more frames are available or an error was encountered.
+func (s *FrameScanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	var skipped int64
+	for ; s.off+4 <= s.end; s.off, skipped = s.off+1, skipped+1 {
+		fi, err := s.readHeader(s.off)
+		if err != nil {
+			s.err = err
+			return false
+		} else if fi == nil {
+			s.synced = false
+			continue
+		}
+
+		// Check for truncation before checking the next frame, since a truncated final
+		// frame isn't followed by anything.
+		size := fi.Size()
+		if s.off+size > s.end {
+			// We found a valid header, but the frame extends past the end of the audio data.
+			s.truncated = &Frame{Offset: s.off, Size: size, Info: fi, Skipped: skipped}
+			s.trailing = skipped + s.end - s.off
+			s.skipped += s.trailing
+			s.off = s.end
+			return false
+		}
+		if !s.synced && !s.checkNext(s.off, fi) {
+			continue
+		}
+
+		s.frame = Frame{Offset: s.off, Size: size, Info: fi, Skipped: skipped}
+		s.skipped += skipped
+		s.off += size
+		s.synced = true
+		return true
+	}
+
+	// Count any bytes that we skipped at the end of the data.
+	skipped += s.end - s.off
+	if skipped > 0 {
+		s.skipped += skipped
+		s.trailing = skipped
+	}
+	s.off = s.end
+	return false
+}
+
+// Frame returns the frame that was found by the last successful call to Next.
+func (s *FrameScanner) Frame() Frame { return s.frame }
+
+// Err returns the first non-EOF error that was encountered.
+func (s *FrameScanner) Err() error { return s.err }
+
+// SkippedBytes returns the total number of unparseable bytes that have been skipped,
+// including ones after the final frame.
+func (s *FrameScanner) SkippedBytes() int64 { return s.skipped }
+
+// TrailingBytes returns the number of unparseable bytes that were skipped after the final frame.
+// It is only meaningful after Next has returned false.
+func (s *FrameScanner) TrailingBytes() int64 { return s.trailing }
+
+// Truncated returns the final frame if its header was valid but the frame extended
+// past the end of the audio data, or nil otherwise. The frame's bytes are included
+// in TrailingBytes. It is only meaningful after Next has returned false.
+func (s *FrameScanner) Truncated() *Frame { return s.truncated }
+
+// readHeader reads and parses the frame header at off.
+// A nil FrameInfo and nil error are returned if the data doesn't contain a valid header.
+func (s *FrameScanner) readHeader(off int64) (*FrameInfo, error) {
+	b, err := s.peek(off, 4)
+	if err != nil {
+		return nil, err
+	} else if len(b) < 4 {
+		return nil, nil
+	}
+	header := binary.BigEndian.Uint32(b)
+	fi, err := parseFrameHeader(header)
+	if err != nil {
+		return nil, nil
+	}
+	if fi.FreeFormat {
+		if b, err = s.peek(off, maxFreeFormatSize+4); err != nil {
+			return nil, err
+		}
+		if !fi.inferFreeFormatSize(header, b) {
+			// The final frame isn't followed by another frame, so assume that it's
+			// the same size as the previous one.
+			prev := s.frame.Info
+			if prev == nil || !prev.FreeFormat || prev.Version != fi.Version ||
+				prev.Layer != fi.Layer || prev.SampleRate != fi.SampleRate {
+				return nil, nil
+			}
+			fi.freeSlots = prev.freeSlots
+			fi.KbitRate = prev.KbitRate
+		}
+	}
+	return fi, nil
+}
+
+// checkNext returns true if the frame described by fi at off is followed by
+// a frame with a compatible header or by the end of the audio data.
+// This is used to avoid treating junk as a frame when resynchronizing.
+func (s *FrameScanner) checkNext(off int64, fi *FrameInfo) bool {
+	next := off + fi.Size()
+	if next == s.end {
+		return true
+	}
+	nfi, err := s.readHeader(next)
+	if err != nil || nfi == nil {
+		return false
+	}
+	return nfi.Version == fi.Version && nfi.Layer == fi.Layer && nfi.SampleRate == fi.SampleRate
+}
+
+// peek returns up to n bytes starting at off, stopping at the end of the audio data.
+func (s *FrameScanner) peek(off int64, n int) ([]byte, error) {
+	if off >= s.bufOff && off+int64(n) <= s.bufOff+int64(len(s.buf)) {
+		i := int(off - s.bufOff)
+		return s.buf[i : i+n], nil
+	}
+
+	size := int64(scanBufSize)
+	if rem := s.end - off; rem < size {
+		size = rem
+	}
+	if size <= 0 {
+		return nil, nil
+	}
+	if int64(cap(s.buf)) < size {
+		s.buf = make([]byte, size)
+	}
+	s.buf = s.buf[:size]
+	nr, err := s.r.ReadAt(s.buf, off)
+	s.buf, s.bufOff = s.buf[:nr], off
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if nr < n {
+		return s.buf, nil
+	}
+	return s.buf[:n], nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const (
+	// MPEG-1 Layer III, no CRC, 128 kbps, 44100 Hz, no padding, stereo.
+	testHeader128 uint32 = 0xfffb9000
+	// Same as testHeader128, but with padding.
+	testHeader128Pad uint32 = 0xfffb9200
+)
+
+// makeFrame returns a frame with the supplied header. The frame's data is filled with fill.
+func makeFrame(t *testing.T, header uint32, fill byte) []byte {
+	fi, err := parseFrameHeader(header)
+	if err != nil {
+		t.Fatalf("Bad header %#x: %v", header, err)
+	}
+	b := bytes.Repeat([]byte{fill}, int(fi.Size()))
+	binary.BigEndian.PutUint32(b, header)
+	return b
+}
+
+func TestFrameScanner(t *testing.T) {
+	var data []byte
+	add := func(b []byte) int64 {
+		off := int64(len(data))
+		data = append(data, b...)
+		return off
+	}
+
+	header := add([]byte("fake header"))
+	junk1 := add(make([]byte, 10))
+	f1 := add(makeFrame(t, testHeader128, 0))
+	f2 := add(makeFrame(t, testHeader128Pad, 0))
+	f3 := add(makeFrame(t, testHeader128, 0))
+	junk2 := add([]byte{0x12, 0x34, 0x56, 0x78, 0x9a})
+	f4 := add(makeFrame(t, testHeader128, 0))
+	f5 := add(makeFrame(t, testHeader128, 0))
+	trunc := add(makeFrame(t, testHeader128, 0)[:100])
+	footer := add([]byte("footer"))
+
+	type result struct{ off, size, skipped int64 }
+	want := []result{
+		{f1, 417, f1 - junk1},
+		{f2, 418, 0},
+		{f3, 417, 0},
+		{f4, 417, f4 - junk2},
+		{f5, 417, 0},
+	}
+
+	s := NewFrameScanner(bytes.NewReader(data), int64(len(data)), junk1-header, int64(len(data))-footer)
+	var got []result
+	for s.Next() {
+		f := s.Frame()
+		got = append(got, result{f.Offset, f.Size, f.Skipped})
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal("Scanning failed: ", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got %d frames %v; want %d frames %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Frame %d is %+v; want %+v", i, got[i], want[i])
+		}
+	}
+
+	if tr := s.Truncated(); tr == nil || tr.Offset != trunc {
+		t.Errorf("Truncated() = %+v; want frame at %d", tr, trunc)
+	}
+	if got, want := s.TrailingBytes(), footer-trunc; got != want {
+		t.Errorf("TrailingBytes() = %d; want %d", got, want)
+	}
+	if got, want := s.SkippedBytes(), (f1-junk1)+(f4-junk2)+(footer-trunc); got != want {
+		t.Errorf("SkippedBytes() = %d; want %d", got, want)
+	}
+}
+
+func TestFrameScanner_TruncatedAfterJunk(t *testing.T) {
+	frame := makeFrame(t, testHeader128, 0)
+	junk := []byte{0x12, 0x34, 0x56, 0x78, 0x9a}
+	for _, tc := range []struct {
+		desc   string
+		data   []byte
+		frames int
+		trunc  int64 // offset of truncated frame
+	}{
+		{"junk at start", append(append([]byte{}, junk...), frame[:100]...), 0, 5},
+		{"junk between", bytes.Join([][]byte{frame, frame, junk, frame[:100]}, nil), 2, 2*417 + 5},
+	} {
+		s := NewFrameScanner(bytes.NewReader(tc.data), int64(len(tc.data)), 0, 0)
+		var n int
+		for ; s.Next(); n++ {
+		}
+		if err := s.Err(); err != nil {
+			t.Errorf("Scanning %v failed: %v", tc.desc, err)
+			continue
+		}
+		if n != tc.frames {
+			t.Errorf("Scanning %v found %d frame(s); want %d", tc.desc, n, tc.frames)
+		}
+		if tr := s.Truncated(); tr == nil || tr.Offset != tc.trunc || tr.Skipped != 5 {
+			t.Errorf("Truncated() for %v = %+v; want frame at %d after 5 skipped bytes", tc.desc, tr, tc.trunc)
+		}
+		if got, want := s.TrailingBytes(), int64(len(tc.data))-tc.trunc+5; got != want {
+			t.Errorf("TrailingBytes() for %v = %d; want %d", tc.desc, got, want)
+		}
+	}
+}
+
+func TestFrameScanner_FreeFormat(t *testing.T) {
+	const (
+		header    = 0xfffb0000 // MPEG-1 Layer III, free format, 44100 Hz
+		frameSize = 2089       // 640 kbps
+		nframes   = 5
+	)
+	var data []byte
+	for i := 0; i < nframes; i++ {
+		f := make([]byte, frameSize)
+		binary.BigEndian.PutUint32(f, header)
+		data = append(data, f...)
+	}
+	r := bytes.NewReader(data)
+
+	fi, err := ReadFrameInfoFrom(r, 0)
+	if err != nil {
+		t.Fatal("ReadFrameInfoFrom failed: ", err)
+	}
+	if !fi.FreeFormat || fi.Size() != frameSize || fi.KbitRate != 640 {
+		t.Errorf("ReadFrameInfoFrom returned free format %v, size %d, bitrate %d; want %v, %d, %d",
+			fi.FreeFormat, fi.Size(), fi.KbitRate, true, frameSize, 640)
+	}
+
+	s := NewFrameScanner(r, int64(len(data)), 0, 0)
+	var n int
+	for ; s.Next(); n++ {
+		if f := s.Frame(); f.Offset != int64(n*frameSize) || f.Size != frameSize {
+			t.Errorf("Frame %d at %d has size %d; want %d at %d", n, f.Offset, f.Size, frameSize, n*frameSize)
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal("Scanning failed: ", err)
+	}
+	if n != nframes {
+		t.Errorf("Scanner found %d frame(s); want %d", n, nframes)
+	}
+	if s.SkippedBytes() != 0 {
+		t.Errorf("Scanner skipped %d byte(s)", s.SkippedBytes())
+	}
+
+	if ed, err := ComputeExactAudioDurationFrom(r, int64(len(data)), 0, 0); err != nil {
+		t.Error("ComputeExactAudioDurationFrom failed: ", err)
+	} else if ed.Frames != nframes {
+		t.Errorf("ComputeExactAudioDurationFrom found %d frame(s); want %d", ed.Frames, nframes)
+	}
+}
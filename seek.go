@@ -0,0 +1,360 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// ComputeSeekOffset returns the approximate offset in f of the frame from which playback should
+// start in order to seek to pos. headerLen and footerLen are described by NewFrameScanner.
+//
+// The Xing header's TOC is used if present. Otherwise, the VBRI header's TOC is used if present,
+// and the position is linearly interpolated (i.e. a constant bitrate is assumed) if neither header
+// is present. The estimated offset is then advanced to the start of the next frame.
+func ComputeSeekOffset(f *os.File, fi os.FileInfo, headerLen, footerLen int64, pos time.Duration) (int64, error) {
+	return ComputeSeekOffsetFrom(f, fi.Size(), headerLen, footerLen, pos)
+}
+
+// ComputeSeekOffsetFrom is similar to ComputeSeekOffset but reads from r, which contains size bytes.
+func ComputeSeekOffsetFrom(r io.ReaderAt, size, headerLen, footerLen int64, pos time.Duration) (int64, error) {
+	return computeSeekOffset(r, size, headerLen, footerLen, func(dur time.Duration) float64 {
+		if dur <= 0 {
+			return 0
+		}
+		return float64(pos) / float64(dur)
+	})
+}
+
+// ComputeSeekOffsetPercent is similar to ComputeSeekOffset but takes a position as
+// a percentage of the audio's duration in the range [0, 100].
+func ComputeSeekOffsetPercent(f *os.File, fi os.FileInfo, headerLen, footerLen int64, pct float64) (int64, error) {
+	return ComputeSeekOffsetPercentFrom(f, fi.Size(), headerLen, footerLen, pct)
+}
+
+// ComputeSeekOffsetPercentFrom is similar to ComputeSeekOffsetPercent but reads from r,
+// which contains size bytes.
+func ComputeSeekOffsetPercentFrom(r io.ReaderAt, size, headerLen, footerLen int64, pct float64) (int64, error) {
+	return computeSeekOffset(r, size, headerLen, footerLen, func(time.Duration) float64 { return pct / 100 })
+}
+
+// computeSeekOffset implements ComputeSeekOffsetFrom and ComputeSeekOffsetPercentFrom.
+// getFrac is passed the audio's duration and should return the requested position
+// as a fraction of it.
+func computeSeekOffset(r io.ReaderAt, size, headerLen, footerLen int64,
+	getFrac func(dur time.Duration) float64) (int64, error) {
+	fstart, finfo, err := findFirstFrame(r, headerLen)
+	if err != nil {
+		return 0, err
+	}
+	vbrInfo, err := readVBRInfo(r, size, fstart, finfo)
+	if err != nil {
+		return 0, err
+	}
+
+	end := size - footerLen
+	astart := fstart // start of audio frames
+	var dur time.Duration
+	if vbrInfo != nil {
+		astart += finfo.Size()
+		dur = samplesToDuration(int64(vbrInfo.Frames)*int64(finfo.SamplesPerFrame), finfo.SampleRate)
+	} else if finfo.KbitRate == 0 {
+		return 0, errors.New("unknown bitrate")
+	} else {
+		dur = time.Duration((end-fstart)*8) * time.Millisecond / time.Duration(finfo.KbitRate)
+	}
+
+	frac := getFrac(dur)
+	if frac < 0 {
+		frac = 0
+	} else if frac > 1 {
+		frac = 1
+	}
+
+	var off int64
+	switch {
+	case vbrInfo != nil && vbrInfo.TOC != nil:
+		off = fstart + xingTOCOffset(vbrInfo.TOC, frac, vbrInfo.Bytes, end-fstart)
+	case vbrInfo != nil && vbrInfo.VBRI != nil:
+		off = astart + vbriTOCOffset(vbrInfo.VBRI, frac, vbrInfo.Frames)
+	default:
+		off = astart + int64(frac*float64(end-astart))
+	}
+	if off < astart {
+		off = astart
+	}
+
+	// Find the start of the next frame.
+	s := NewFrameScanner(r, size, off, footerLen)
+	if s.Next() {
+		return s.Frame().Offset, nil
+	}
+	return off, s.Err()
+}
+
+// xingTOCOffset uses the supplied Xing TOC to return the offset from the beginning of the
+// Xing frame corresponding to frac (in the range [0, 1]) of the audio's duration.
+// nbytes is the byte count from the Xing header. If it is zero, def is used instead.
+func xingTOCOffset(toc []byte, frac float64, nbytes uint32, def int64) int64 {
+	total := float64(nbytes)
+	if total == 0 {
+		total = float64(def)
+	}
+
+	// Interpolate between adjacent entries.
+	pct := frac * 100
+	i := int(pct)
+	if i > 99 {
+		i = 99
+	}
+	a, b := float64(toc[i]), 256.0
+	if i < 99 {
+		b = float64(toc[i+1])
+	}
+	x := a + (b-a)*(pct-float64(i))
+	return int64(x / 256 * total)
+}
+
+// vbriTOCOffset uses the supplied VBRI header to return the offset from the end of the VBRI frame
+// corresponding to frac (in the range [0, 1]) of the audio's duration. nframes is the
+// number of frames from the VBRI header.
+func vbriTOCOffset(info *VBRIInfo, frac float64, nframes uint32) int64 {
+	if info.FramesPerEntry <= 0 {
+		return 0
+	}
+	entry := frac * float64(nframes) / float64(info.FramesPerEntry)
+	var off int64
+	for i, n := range info.TOC {
+		if float64(i+1) > entry {
+			// Interpolate within the entry.
+			return off + int64((entry-float64(i))*float64(n))
+		}
+		off += n
+	}
+	return off
+}
+
+// SeekIndex maps sample positions to the offsets of the frames containing them.
+// It is built by scanning every frame in a file, so it's more accurate than the
+// Xing header's TOC.
+type SeekIndex struct {
+	rate    int         // sample rate in hertz
+	frames  []seekFrame // ordered by offset
+	samples int64       // total number of samples
+}
+
+// seekFrame describes a single frame in a SeekIndex.
+type seekFrame struct {
+	off   int64 // offset of frame's header
+	start int64 // index of frame's first sample
+}
+
+// BuildSeekIndex scans all of the frames in f to build a SeekIndex. headerLen and footerLen
+// are described by NewFrameScanner. A frame containing an Xing, Info, or VBRI header is excluded.
+func BuildSeekIndex(f *os.File, fi os.FileInfo, headerLen, footerLen int64) (*SeekIndex, error) {
+	return BuildSeekIndexFrom(f, fi.Size(), headerLen, footerLen)
+}
+
+// BuildSeekIndexFrom is similar to BuildSeekIndex but reads from r, which contains size bytes.
+func BuildSeekIndexFrom(r io.ReaderAt, size, headerLen, footerLen int64) (*SeekIndex, error) {
+	var idx SeekIndex
+	s := NewFrameScanner(r, size, headerLen, footerLen)
+	for first := true; s.Next(); first = false {
+		f := s.Frame()
+		if first {
+			if vbr, err := hasVBRHeader(r, f.Offset, f.Info); err != nil {
+				return nil, err
+			} else if vbr {
+				continue
+			}
+		}
+		if idx.rate == 0 {
+			idx.rate = f.Info.SampleRate
+		} else if f.Info.SampleRate != idx.rate {
+			// Sample positions can't be converted to times if the rate changes.
+			return nil, fmt.Errorf("frame at %#x has sample rate %d Hz; first frame has %d Hz",
+				f.Offset, f.Info.SampleRate, idx.rate)
+		}
+		idx.frames = append(idx.frames, seekFrame{f.Offset, idx.samples})
+		idx.samples += int64(f.Info.SamplesPerFrame)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// Frames returns the number of frames in idx.
+func (idx *SeekIndex) Frames() int { return len(idx.frames) }
+
+// SampleRate returns the sample rate of the frames in idx, in hertz.
+func (idx *SeekIndex) SampleRate() int { return idx.rate }
+
+// Samples returns the total number of samples (per channel) in idx.
+func (idx *SeekIndex) Samples() int64 { return idx.samples }
+
+// Duration returns the total duration of the frames in idx.
+func (idx *SeekIndex) Duration() time.Duration {
+	if idx.rate == 0 {
+		return 0
+	}
+	return samplesToDuration(idx.samples, idx.rate)
+}
+
+// LookupSample returns the offset of the frame containing the supplied sample
+// and the index of the frame's first sample. If sample is out of range, false is returned.
+func (idx *SeekIndex) LookupSample(sample int64) (off, start int64, ok bool) {
+	if sample < 0 || sample >= idx.samples {
+		return 0, 0, false
+	}
+	// Find the first frame starting after the sample and use the one before it.
+	i := sort.Search(len(idx.frames), func(i int) bool { return idx.frames[i].start > sample }) - 1
+	if i < 0 {
+		return 0, 0, false
+	}
+	return idx.frames[i].off, idx.frames[i].start, true
+}
+
+// LookupTime is similar to LookupSample but takes and returns positions as durations.
+func (idx *SeekIndex) LookupTime(t time.Duration) (off int64, start time.Duration, ok bool) {
+	if idx.rate == 0 || t < 0 {
+		return 0, 0, false
+	}
+	sample := int64(t / time.Second * time.Duration(idx.rate))
+	sample += int64(t%time.Second) * int64(idx.rate) / int64(time.Second)
+	off, first, ok := idx.LookupSample(sample)
+	if !ok {
+		return 0, 0, false
+	}
+	return off, samplesToDuration(first, idx.rate), true
+}
+
+// seekIndexMagic and seekIndexVersion are written at the beginning of a serialized SeekIndex.
+const (
+	seekIndexMagic   = "MSIX"
+	seekIndexVersion = 1
+)
+
+// MarshalBinary serializes idx to a compact binary form. It implements encoding.BinaryMarshaler.
+//
+// The serialized index consists of a magic string and version followed by varints containing
+// the sample rate and number of frames and then, for each frame, the distance in bytes from
+// the previous frame and the number of samples in the previous frame (or the offset of the
+// first frame and zero).
+func (idx *SeekIndex) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(seekIndexMagic)+1+2*binary.MaxVarintLen64+len(idx.frames)*4)
+	b = append(b, seekIndexMagic...)
+	b = append(b, seekIndexVersion)
+	b = appendUvarint(b, uint64(idx.rate))
+	b = appendUvarint(b, uint64(len(idx.frames)))
+
+	var prev seekFrame
+	for _, f := range idx.frames {
+		b = appendUvarint(b, uint64(f.off-prev.off))
+		b = appendUvarint(b, uint64(f.start-prev.start))
+		prev = f
+	}
+	// Write the length of the final frame so the total number of samples can be recovered.
+	return appendUvarint(b, uint64(idx.samples-prev.start)), nil
+}
+
+// UnmarshalBinary deserializes data written by MarshalBinary into idx.
+// It implements encoding.BinaryUnmarshaler.
+func (idx *SeekIndex) UnmarshalBinary(data []byte) error {
+	if len(data) < len(seekIndexMagic)+1 || string(data[:len(seekIndexMagic)]) != seekIndexMagic {
+		return errors.New("not a seek index")
+	}
+	if v := data[len(seekIndexMagic)]; v != seekIndexVersion {
+		return fmt.Errorf("unsupported seek index version %d", v)
+	}
+	r := bytes.NewReader(data[len(seekIndexMagic)+1:])
+	read := func() (int64, error) {
+		v, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return int64(v), err
+	}
+
+	rate, err := read()
+	if err != nil {
+		return err
+	}
+	n, err := read()
+	if err != nil {
+		return err
+	} else if n < 0 || n > int64(len(data)) { // each frame needs at least two bytes
+		return fmt.Errorf("bad frame count %d", n)
+	}
+	if (n == 0 && rate != 0) || (n > 0 && !isSampleRate(rate)) {
+		return fmt.Errorf("bad sample rate %d", rate)
+	}
+	frames := make([]seekFrame, n)
+	var prev seekFrame
+	for i := range frames {
+		doff, err := read()
+		if err != nil {
+			return err
+		}
+		dstart, err := read()
+		if err != nil {
+			return err
+		}
+		f := seekFrame{prev.off + doff, prev.start + dstart}
+		if i == 0 && f.start != 0 {
+			return fmt.Errorf("first frame starts at sample %d", f.start)
+		} else if i > 0 && (f.off <= prev.off || !isFrameSampleCount(dstart)) {
+			return fmt.Errorf("frame %d isn't after previous frame", i)
+		}
+		frames[i] = f
+		prev = f
+	}
+	last, err := read()
+	if err != nil {
+		return err
+	}
+	if (n == 0 && last != 0) || (n > 0 && !isFrameSampleCount(last)) {
+		return fmt.Errorf("bad final frame length %d", last)
+	}
+
+	*idx = SeekIndex{rate: int(rate), frames: frames, samples: prev.start + last}
+	return nil
+}
+
+// isSampleRate returns true if rate is a valid MPEG audio sample rate in hertz.
+func isSampleRate(rate int64) bool {
+	for _, rates := range sampleRates {
+		for _, r := range rates {
+			if r != 0 && int64(r) == rate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isFrameSampleCount returns true if n is a valid number of samples in an MPEG audio frame.
+func isFrameSampleCount(n int64) bool {
+	for _, counts := range samplesPerFrame {
+		for _, c := range counts {
+			if int64(c) == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendUvarint appends v to b as a uvarint.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
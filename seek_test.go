@@ -0,0 +1,170 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeSeekOffset(t *testing.T) {
+	const (
+		nframes   = 10
+		frameSize = 417
+	)
+	var audio []byte
+	for i := 0; i < nframes; i++ {
+		audio = append(audio, makeFrame(t, testHeader128, 0)...)
+	}
+	toc := make([]byte, 100)
+	for i := range toc {
+		toc[i] = byte(i * 256 / 100)
+	}
+	xing := append(makeXingFrame(t, nframes, (nframes+1)*frameSize, toc, nil), audio...)
+
+	for _, tc := range []struct {
+		data []byte
+		pct  float64
+		want int64
+	}{
+		{xing, 0, frameSize},
+		{xing, 50, 6 * frameSize},
+		{xing, 100, int64(len(xing))},
+		{audio, 0, 0},
+		{audio, 50, 5 * frameSize},
+		{audio, 73, 8 * frameSize},
+	} {
+		if got, err := ComputeSeekOffsetPercentFrom(bytes.NewReader(tc.data), int64(len(tc.data)), 0, 0, tc.pct); err != nil {
+			t.Errorf("ComputeSeekOffsetPercentFrom(%d bytes, %v) failed: %v", len(tc.data), tc.pct, err)
+		} else if got != tc.want {
+			t.Errorf("ComputeSeekOffsetPercentFrom(%d bytes, %v) = %d; want %d", len(tc.data), tc.pct, got, tc.want)
+		}
+	}
+
+	// Seek halfway through the audio using a time.
+	dur := nframes * 1152 * time.Second / 44100
+	if got, err := ComputeSeekOffsetFrom(bytes.NewReader(xing), int64(len(xing)), 0, 0, dur/2); err != nil {
+		t.Error("ComputeSeekOffsetFrom failed: ", err)
+	} else if want := int64(6 * frameSize); got != want {
+		t.Errorf("ComputeSeekOffsetFrom(%v) = %d; want %d", dur/2, got, want)
+	}
+}
+
+func TestComputeSeekOffset_UnknownBitrate(t *testing.T) {
+	// Padded free-format MPEG-1 Layer I frames consisting of only the padding slot
+	// have an inferred bitrate of 0.
+	data := bytes.Repeat([]byte{0xff, 0xff, 0x02, 0x00}, 10)
+	if got, err := ComputeSeekOffsetPercentFrom(bytes.NewReader(data), int64(len(data)), 0, 0, 50); err == nil {
+		t.Errorf("ComputeSeekOffsetPercentFrom unexpectedly returned %d", got)
+	}
+}
+
+func TestSeekIndex(t *testing.T) {
+	const (
+		nframes   = 10
+		frameSize = 417
+		spf       = 1152
+	)
+	data := makeXingFrame(t, nframes, (nframes+1)*frameSize, make([]byte, 100), nil)
+	for i := 0; i < nframes; i++ {
+		data = append(data, makeFrame(t, testHeader128, 0)...)
+	}
+
+	idx, err := BuildSeekIndexFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+	if err != nil {
+		t.Fatal("BuildSeekIndexFrom failed: ", err)
+	}
+	if got := idx.Frames(); got != nframes {
+		t.Errorf("Frames() = %d; want %d", got, nframes)
+	}
+	if got, want := idx.Samples(), int64(nframes*spf); got != want {
+		t.Errorf("Samples() = %d; want %d", got, want)
+	}
+
+	enc, err := idx.MarshalBinary()
+	if err != nil {
+		t.Fatal("MarshalBinary failed: ", err)
+	}
+	var dec SeekIndex
+	if err := dec.UnmarshalBinary(enc); err != nil {
+		t.Fatal("UnmarshalBinary failed: ", err)
+	}
+	if !reflect.DeepEqual(dec, *idx) {
+		t.Errorf("UnmarshalBinary returned %+v; want %+v", dec, *idx)
+	}
+	if err := dec.UnmarshalBinary(enc[:len(enc)-1]); err == nil {
+		t.Error("UnmarshalBinary unexpectedly succeeded for truncated data")
+	}
+	for _, tc := range []struct {
+		desc string
+		vals []uint64 // rate, frames, (doff, dstart)..., last
+	}{
+		{"no frames with samples", []uint64{44100, 0, 1000}},
+		{"first frame not at sample 0", []uint64{44100, 1, 0, 5, 1152}},
+		{"repeated offset", []uint64{44100, 2, 10, 0, 0, 1152, 1152}},
+		{"repeated start", []uint64{44100, 2, 10, 0, 417, 0, 1152}},
+		{"overflowing offset", []uint64{44100, 2, 10, 0, 1 << 63, 1152, 1152}},
+		{"zero rate", []uint64{0, 1, 0, 0, 1152}},
+		{"bogus rate", []uint64{12345, 1, 0, 0, 1152}},
+		{"rate without frames", []uint64{44100, 0, 0}},
+		{"bogus frame length", []uint64{44100, 2, 10, 0, 417, 1000, 1152}},
+		{"bogus final frame length", []uint64{44100, 1, 0, 0, 1000}},
+	} {
+		b := []byte(seekIndexMagic + "\x01")
+		for _, v := range tc.vals {
+			b = appendUvarint(b, v)
+		}
+		var bad SeekIndex
+		if err := bad.UnmarshalBinary(b); err == nil {
+			t.Errorf("UnmarshalBinary unexpectedly succeeded for %v", tc.desc)
+			if _, _, ok := bad.LookupSample(0); ok {
+				t.Errorf("LookupSample(0) unexpectedly succeeded for %v", tc.desc)
+			}
+		}
+	}
+	if _, _, ok := (&SeekIndex{rate: 44100, samples: 1000}).LookupSample(0); ok {
+		t.Error("LookupSample(0) unexpectedly succeeded for index without frames")
+	}
+
+	for _, tc := range []struct {
+		sample    int64
+		off, want int64
+		ok        bool
+	}{
+		{0, frameSize, 0, true},
+		{spf - 1, frameSize, 0, true},
+		{3*spf + 5, 4 * frameSize, 3 * spf, true},
+		{nframes*spf - 1, nframes * frameSize, (nframes - 1) * spf, true},
+		{nframes * spf, 0, 0, false},
+		{-1, 0, 0, false},
+	} {
+		off, start, ok := idx.LookupSample(tc.sample)
+		if off != tc.off || start != tc.want || ok != tc.ok {
+			t.Errorf("LookupSample(%d) = %d, %d, %v; want %d, %d, %v",
+				tc.sample, off, start, ok, tc.off, tc.want, tc.ok)
+		}
+	}
+
+	pos := 3*spf*time.Second/44100 + time.Millisecond
+	if off, start, ok := idx.LookupTime(pos); !ok || off != 4*frameSize || start != 3*spf*time.Second/44100 {
+		t.Errorf("LookupTime(%v) = %d, %v, %v; want %d, %v, true",
+			pos, off, start, ok, 4*frameSize, 3*spf*time.Second/44100)
+	}
+}
+
+func TestBuildSeekIndex_MixedSampleRates(t *testing.T) {
+	const header48 = 0xfffb9400 // MPEG-1 Layer III, 128 kbps, 48000 Hz
+	var data []byte
+	for i := 0; i < 3; i++ {
+		data = append(data, makeFrame(t, testHeader128, 0)...)
+	}
+	for i := 0; i < 3; i++ {
+		data = append(data, makeFrame(t, header48, 0)...)
+	}
+	if _, err := BuildSeekIndexFrom(bytes.NewReader(data), int64(len(data)), 0, 0); err == nil {
+		t.Error("BuildSeekIndexFrom unexpectedly succeeded for mixed sample rates")
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// VBRInfo contains information from an Xing (or Info) or VBRI header in the first frame.
+// See https://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header#XINGHeader.
+type VBRInfo struct {
+	// ID contains the ID from the beginning of the header.
+	ID VBRHeaderID
+	// Frames contains the number of audio frames in the file.
+	Frames uint32
+	// Bytes contains the number of bytes of audio data in the file.
+	Bytes uint32
+	// Quality contains a poorly-defined quality indicator in the range [0, 100].
+	Quality int
+	// TOC contains the Xing header's 100-entry table of contents, or nil if it isn't present.
+	// The i-th entry describes the position at i% of the audio duration as a fraction
+	// (out of 256) of Bytes.
+	TOC []byte
+	// Encoder describes the encoder version, e.g. "LAME3.90a".
+	Encoder string
+	// Method describes how the audio was encoded.
+	Method EncodingMethod
+	// LAME contains information from a LAME extension following an Xing header.
+	// It is nil if the extension is not present.
+	LAME *LAMEInfo
+	// VBRI contains additional information from a VBRI header.
+	// It is only set if ID is VBRIID.
+	VBRI *VBRIInfo
+}
+
+// VBRHeaderID describes the type of header used to fill a VBRInfo.
+type VBRHeaderID string
+
+const (
+	// XingID typically indicates a VBR or ABR stream.
+	XingID VBRHeaderID = "Xing"
+	// InfoID typically indicates a CBR stream.
+	InfoID VBRHeaderID = "Info"
+	// VBRIID indicates a VBR stream encoded by the Fraunhofer encoder.
+	VBRIID VBRHeaderID = "VBRI"
+)
+
+// VBRIInfo contains information from a VBRI header that isn't present in Xing headers.
+// See https://www.codeproject.com/Articles/8295/MPEG-Audio-Frame-Header#VBRIHeader.
+type VBRIInfo struct {
+	// Version contains the header's version ID.
+	Version int
+	// Delay contains the encoder delay.
+	Delay int
+	// FramesPerEntry contains the number of frames described by each entry in TOC.
+	FramesPerEntry int
+	// TOC contains the number of bytes in each successive group of FramesPerEntry frames.
+	TOC []int64
+}
+
+// vbrHeaderError is returned by readVBRInfo when a VBR header is malformed.
+type vbrHeaderError struct{ msg string }
+
+func (e *vbrHeaderError) Error() string { return e.msg }
+
+// isBadVBRHeader returns true if err indicates that a VBR header is malformed or truncated
+// (as opposed to the underlying data being unreadable).
+func isBadVBRHeader(err error) bool {
+	_, ok := err.(*vbrHeaderError)
+	return ok || err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// readVBRInfo reads an Xing or VBRI header from the frame described by fi at off in r,
+// which contains size bytes. If neither header is present, nil is returned.
+func readVBRInfo(r io.ReaderAt, size, off int64, fi *FrameInfo) (*VBRInfo, error) {
+	// VBR headers are only written to Layer III frames.
+	if fi.Layer != Layer3 {
+		return nil, nil
+	}
+	if info, _, err := readXingHeader(r, size, off, fi); err != nil || info != nil {
+		return info, err
+	}
+	return readVBRIHeader(r, size, off)
+}
+
+// readXingHeader reads an Xing (or Info) header from the frame described by fi at off in r,
+// which contains size bytes. If the header isn't present, nil is returned. The offset in r of the
+// LAME extension (i.e. of the end of the Xing header's fields) is also returned.
+func readXingHeader(r io.ReaderAt, size, off int64, fi *FrameInfo) (*VBRInfo, int64, error) {
+	xingStart := off + xingOffset(fi)
+	if xingStart+4 > size {
+		return nil, 0, nil
+	}
+	f := io.NewSectionReader(r, xingStart, size-xingStart)
+
+	// Read 4-byte ID at beginning of header.
+	id := make([]byte, 4)
+	if _, err := io.ReadFull(f, id); err != nil {
+		return nil, 0, err
+	}
+	if VBRHeaderID(id) != XingID && VBRHeaderID(id) != InfoID {
+		return nil, 0, nil
+	}
+	vbrInfo := VBRInfo{ID: VBRHeaderID(id)}
+
+	// Read 4-byte flags indicating which fields are present.
+	var flags uint32
+	if err := binary.Read(f, binary.BigEndian, &flags); err != nil {
+		return nil, 0, err
+	}
+
+	// Read 4-byte frame count. This is optional in the spec, but we require it since it's
+	// needed to compute the duration.
+	if flags&0x1 == 0 {
+		return nil, 0, &vbrHeaderError{"Xing header lacks number of frames"}
+	}
+	if err := binary.Read(f, binary.BigEndian, &vbrInfo.Frames); err != nil {
+		return nil, 0, err
+	}
+
+	// Read 4-byte byte count if present.
+	if flags&0x2 != 0 {
+		if err := binary.Read(f, binary.BigEndian, &vbrInfo.Bytes); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Read 100-byte TOC if present.
+	if flags&0x4 != 0 {
+		vbrInfo.TOC = make([]byte, 100)
+		if _, err := io.ReadFull(f, vbrInfo.TOC); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// Read 4-byte quality indicator if present.
+	if flags&0x8 != 0 {
+		var quality uint32
+		if err := binary.Read(f, binary.BigEndian, &quality); err != nil {
+			return nil, 0, err
+		}
+		vbrInfo.Quality = int(quality)
+	}
+
+	// Try to read the LAME extension:
+	// http://gabriel.mp3-tech.org/mp3infotag.html
+	lameStart, _ := f.Seek(0, io.SeekCurrent)
+	lameStart += xingStart
+	b := make([]byte, lameTagLen)
+	if n, _ := io.ReadFull(f, b); n >= 10 {
+		enc := b[:9]
+		ver := (b[9] & 0xf0) >> 4
+		if (ver == 0 || ver == 1) && isEncoderString(enc) {
+			vbrInfo.Encoder = strings.TrimSpace(string(enc))
+			vbrInfo.Method = EncodingMethod(b[9] & 0xf)
+			if n == len(b) {
+				vbrInfo.LAME = parseLAMETag(b)
+			}
+		}
+	}
+
+	return &vbrInfo, lameStart, nil
+}
+
+// vbriOffset is the offset of the VBRI header (if any) from the beginning of the first frame.
+const vbriOffset = 4 + 32
+
+// readVBRIHeader reads a VBRI header from the frame at off in r, which contains size bytes.
+// If the header isn't present, nil is returned.
+func readVBRIHeader(r io.ReaderAt, size, off int64) (*VBRInfo, error) {
+	vbriStart := off + vbriOffset
+	if vbriStart+4 > size {
+		return nil, nil
+	}
+	f := io.NewSectionReader(r, vbriStart, size-vbriStart)
+
+	var hdr struct {
+		ID             [4]byte
+		Version        uint16
+		Delay          uint16
+		Quality        uint16
+		Bytes          uint32
+		Frames         uint32
+		TOCEntries     uint16
+		TOCScale       uint16
+		TOCEntrySize   uint16
+		FramesPerEntry uint16
+	}
+	if _, err := io.ReadFull(f, hdr.ID[:]); err != nil {
+		return nil, err
+	}
+	if VBRHeaderID(hdr.ID[:]) != VBRIID {
+		return nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(f, binary.BigEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.TOCEntrySize < 1 || hdr.TOCEntrySize > 4 {
+		return nil, &vbrHeaderError{fmt.Sprintf("invalid VBRI TOC entry size %d", hdr.TOCEntrySize)}
+	}
+
+	// Each TOC entry contains a big-endian count of bytes that must be multiplied by the scale factor.
+	b := make([]byte, int(hdr.TOCEntries)*int(hdr.TOCEntrySize))
+	if _, err := io.ReadFull(f, b); err != nil {
+		return nil, err
+	}
+	toc := make([]int64, hdr.TOCEntries)
+	for i := range toc {
+		var v int64
+		for _, ch := range b[i*int(hdr.TOCEntrySize) : (i+1)*int(hdr.TOCEntrySize)] {
+			v = v<<8 | int64(ch)
+		}
+		toc[i] = v * int64(hdr.TOCScale)
+	}
+
+	return &VBRInfo{
+		ID:      VBRIID,
+		Frames:  hdr.Frames,
+		Bytes:   hdr.Bytes,
+		Quality: int(hdr.Quality),
+		VBRI: &VBRIInfo{
+			Version:        int(hdr.Version),
+			Delay:          int(hdr.Delay),
+			FramesPerEntry: int(hdr.FramesPerEntry),
+			TOC:            toc,
+		},
+	}, nil
+}
+
+// xingOffset returns the offset of the Xing header (if any) from the beginning of the frame
+// described by fi. The header is located immediately after the frame's side information.
+func xingOffset(fi *FrameInfo) int64 {
+	off := int64(4 + sideInfoLen(fi))
+	if fi.HasCRC {
+		off += 2
+	}
+	return off
+}
+
+// hasVBRHeader returns true if the frame described by fi at off in r contains an Xing, Info,
+// or VBRI header.
+func hasVBRHeader(r io.ReaderAt, off int64, fi *FrameInfo) (bool, error) {
+	if fi.Layer != Layer3 {
+		return false, nil
+	}
+	readID := func(idOff int64) (VBRHeaderID, error) {
+		id := make([]byte, 4)
+		if _, err := r.ReadAt(id, off+idOff); err == io.EOF {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		return VBRHeaderID(id), nil
+	}
+	if id, err := readID(xingOffset(fi)); err != nil || id == XingID || id == InfoID {
+		return err == nil, err
+	}
+	id, err := readID(vbriOffset)
+	return id == VBRIID, err
+}
+
+// isEncoderString returns true if b contains only printable characters.
+func isEncoderString(b []byte) bool {
+	for _, ch := range b {
+		if !strconv.IsPrint(rune(ch)) {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodingMethod describes the encoding method used for the file.
+type EncodingMethod int
+
+const (
+	UnknownMethod EncodingMethod = 0
+	CBR           EncodingMethod = 1
+	ABR           EncodingMethod = 2
+	VBR1          EncodingMethod = 3 // Lame: VBR old / VBR RH
+	VBR2          EncodingMethod = 4 // Lame: VBR MTRH
+	VBR3          EncodingMethod = 5 // LAME: VBR MT
+	VBR4          EncodingMethod = 6
+	CBR2Pass      EncodingMethod = 8
+	ABR2Pass      EncodingMethod = 9
+)
+
+var encMethodNames = map[EncodingMethod]string{
+	UnknownMethod: "unknown",
+	CBR:           "CBR",
+	ABR:           "ABR",
+	VBR1:          "VBR1",
+	VBR2:          "VBR2",
+	VBR3:          "VBR3",
+	VBR4:          "VBR4",
+	CBR2Pass:      "CBR 2-pass",
+	ABR2Pass:      "ABR 2-pass",
+}
+
+func (m EncodingMethod) String() string {
+	if s, ok := encMethodNames[m]; ok {
+		return s
+	}
+	return fmt.Sprintf("invalid (%d)", int(m))
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Daniel Erat.
+// All rights reserved.
+
+package mpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeVBRIFrame returns a frame with a VBRI header containing the supplied values.
+func makeVBRIFrame(t *testing.T, frames, nbytes uint32, framesPerEntry uint16, toc []uint16) []byte {
+	var b bytes.Buffer
+	b.WriteString(string(VBRIID))
+	for _, v := range []interface{}{
+		uint16(1),        // version
+		uint16(1234),     // delay
+		uint16(75),       // quality
+		nbytes,           // bytes
+		frames,           // frames
+		uint16(len(toc)), // TOC entries
+		uint16(2),        // TOC scale
+		uint16(2),        // TOC entry size
+		framesPerEntry,   // frames per TOC entry
+		toc,              // TOC
+	} {
+		if err := binary.Write(&b, binary.BigEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := makeFrame(t, testHeader128, 0)
+	copy(f[vbriOffset:], b.Bytes())
+	return f
+}
+
+// makeXingFrame returns a frame with an Xing header containing the supplied values.
+// toc must contain 100 bytes. lame is appended to the header if non-nil.
+func makeXingFrame(t *testing.T, frames, nbytes uint32, toc, lame []byte) []byte {
+	return makeXingFrameWithHeader(t, testHeader128, frames, nbytes, toc, lame)
+}
+
+// makeXingFrameWithHeader is similar to makeXingFrame but uses the supplied frame header.
+func makeXingFrameWithHeader(t *testing.T, header, frames, nbytes uint32, toc, lame []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(string(XingID))
+	for _, v := range []interface{}{
+		uint32(0xf), // flags
+		frames,      // frames
+		nbytes,      // bytes
+		toc,         // TOC
+		uint32(50),  // quality
+	} {
+		if err := binary.Write(&b, binary.BigEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Write(lame)
+	fi, err := parseFrameHeader(header)
+	if err != nil {
+		t.Fatalf("Bad header %#x: %v", header, err)
+	}
+	f := makeFrame(t, header, 0)
+	copy(f[xingOffset(fi):], b.Bytes())
+	return f
+}
+
+func TestComputeAudioDuration_VBRI(t *testing.T) {
+	data := makeVBRIFrame(t, 100, 41700, 50, []uint16{10000, 10850})
+	for i := 0; i < 5; i++ {
+		data = append(data, makeFrame(t, testHeader128, 0)...)
+	}
+
+	dur, info, err := ComputeAudioDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+	if err != nil {
+		t.Fatal("ComputeAudioDurationFrom failed: ", err)
+	}
+	if want := 2612 * time.Millisecond; dur != want {
+		t.Errorf("ComputeAudioDurationFrom returned duration %v; want %v", dur, want)
+	}
+	want := &VBRInfo{
+		ID:      VBRIID,
+		Frames:  100,
+		Bytes:   41700,
+		Quality: 75,
+		VBRI: &VBRIInfo{
+			Version:        1,
+			Delay:          1234,
+			FramesPerEntry: 50,
+			TOC:            []int64{20000, 21700},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("ComputeAudioDurationFrom returned %+v; want %+v", info, want)
+	}
+}
+
+func TestComputeAudioDuration_XingFlags(t *testing.T) {
+	toc := make([]byte, 100)
+	for i := range toc {
+		toc[i] = byte(i * 2)
+	}
+	for _, tc := range []struct {
+		flags   uint32
+		bytes   uint32
+		toc     []byte
+		quality int
+	}{
+		{0x1, 0, nil, 0},
+		{0x3, 4170, nil, 0},
+		{0x5, 0, toc, 0},
+		{0x9, 0, nil, 50},
+		{0xb, 4170, nil, 50},
+		{0xf, 4170, toc, 50},
+	} {
+		// Only write the fields indicated by the flags.
+		var b bytes.Buffer
+		b.WriteString(string(XingID))
+		vals := []interface{}{tc.flags, uint32(10)}
+		if tc.flags&0x2 != 0 {
+			vals = append(vals, tc.bytes)
+		}
+		if tc.flags&0x4 != 0 {
+			vals = append(vals, tc.toc)
+		}
+		if tc.flags&0x8 != 0 {
+			vals = append(vals, uint32(tc.quality))
+		}
+		for _, v := range vals {
+			if err := binary.Write(&b, binary.BigEndian, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b.Write(testLAMETag)
+		data := makeFrame(t, testHeader128, 0)
+		copy(data[xingOffset(&FrameInfo{}):], b.Bytes())
+
+		dur, info, err := ComputeAudioDurationFrom(bytes.NewReader(data), int64(len(data)), 0, 0)
+		if err != nil {
+			t.Errorf("ComputeAudioDurationFrom failed for flags %#x: %v", tc.flags, err)
+			continue
+		}
+		if want := 261 * time.Millisecond; dur != want {
+			t.Errorf("ComputeAudioDurationFrom for flags %#x returned duration %v; want %v", tc.flags, dur, want)
+		}
+		if info.Frames != 10 || info.Bytes != tc.bytes || !reflect.DeepEqual(info.TOC, tc.toc) ||
+			info.Quality != tc.quality {
+			t.Errorf("ComputeAudioDurationFrom for flags %#x returned frames %d, bytes %d, TOC %v, quality %d; "+
+				"want %d, %d, %v, %d", tc.flags, info.Frames, info.Bytes, info.TOC, info.Quality,
+				10, tc.bytes, tc.toc, tc.quality)
+		}
+		if info.LAME == nil || !reflect.DeepEqual(*info.LAME, testLAMEInfo) {
+			t.Errorf("ComputeAudioDurationFrom for flags %#x returned LAME info %+v; want %+v",
+				tc.flags, info.LAME, testLAMEInfo)
+		}
+	}
+}